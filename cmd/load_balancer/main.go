@@ -33,10 +33,20 @@ var httpServer *http.Server
 
 func NewLoadBalancer(configuration *config.Config) (*server.LoadBalancer, error) {
 
-	lb := &server.LoadBalancer{
-		Configuration: configuration,
-		// ServiceRegistry: NewServiceRegistry,
-		StopChan: make(chan struct{}),
+	registryURL := "http://localhost:10000"
+	if port := os.Getenv("SERVICE_REGISTRY_HTTP_PORT"); port != "" {
+		registryURL = "http://localhost:" + port
+	}
+
+	lb, err := server.NewLoadBalancer(configuration, registryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// layer 4 listens on config.Listen, see LoadBalancer.Start
+	if configuration.Layer == config.LayerFour {
+		fmt.Println("Load Balancer started: ", time.Now().String())
+		return lb, nil
 	}
 
 	LoadBalancerPort := os.Getenv("LOAD_BALANCER_PORT")
@@ -44,6 +44,7 @@ func NewServiceRegistry() (*ServiceRegistry, error) {
 	srMux.HandleFunc("/register", sr.HandleRegister)
 	srMux.HandleFunc("/heartbeat", sr.HandleHeartbeat)
 	srMux.HandleFunc("/remove", sr.HandleRemove)
+	srMux.HandleFunc("/backends", sr.HandleBackends)
 	srMux.HandleFunc("/load-balancer/hello", sr.HandleLoadBalancerHello)
 
 	// grpcServer := grpc.NewServer()
@@ -84,8 +85,23 @@ func (sr *ServiceRegistry) HandleRegister(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// a backend that just registered is up until a heartbeat says otherwise
+	backend.Metrics.HealthCheckStatus = true
+
+	// registering a known URL again updates it, e.g. to change its weight
 	sr.mu.Lock()
-	sr.Backends = append(sr.Backends, &backend)
+	updated := false
+	for i, b := range sr.Backends {
+		if b.URL == backend.URL {
+			backend.Metrics = b.Metrics
+			sr.Backends[i] = &backend
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		sr.Backends = append(sr.Backends, &backend)
+	}
 	sr.mu.Unlock()
 
 	log.Printf("Registered backend: %v", backend)
@@ -139,8 +155,10 @@ func (sr *ServiceRegistry) HandleHeartbeat(w http.ResponseWriter, r *http.Reques
 	sr.mu.Lock()
 	for _, backend := range sr.Backends {
 		if backend.URL == heartbeat.URL {
-			// backend.Status = heartbeat.Status
-			// Update other fields as needed
+			if heartbeat.Metrics != nil {
+				backend.UpdateMetrics(*heartbeat.Metrics)
+			}
+			backend.Metrics.HealthCheckStatus = heartbeat.Status == "running"
 			break
 		}
 	}
@@ -150,6 +168,25 @@ func (sr *ServiceRegistry) HandleHeartbeat(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusOK)
 }
 
+// HandleBackends lists the registered backends for load balancers to route to.
+func (sr *ServiceRegistry) HandleBackends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sr.mu.Lock()
+	data, err := json.Marshal(sr.Backends)
+	sr.mu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to encode backends", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
 func (sr *ServiceRegistry) HandleLoadBalancerHello(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
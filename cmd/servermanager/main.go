@@ -22,6 +22,7 @@ import (
 type ServerInfo struct {
 	Port      int          `json:"port"`
 	URL       string       `json:"url"`
+	Pool      string       `json:"pool,omitempty"`
 	Status    string       `json:"status"`
 	StartedAt time.Time    `json:"started_at"`
 	server    *http.Server `json:"-"`
@@ -66,7 +67,9 @@ func (sm *ServerManager) findFreePort() (int, error) {
 	}
 }
 
-func (sm *ServerManager) AddServer() (*ServerInfo, error) {
+// AddServer starts a server and registers it into the given backend pool
+// (the default pool when empty).
+func (sm *ServerManager) AddServer(pool string) (*ServerInfo, error) {
 	port, err := sm.findFreePort()
 	if err != nil {
 		return nil, err
@@ -75,6 +78,7 @@ func (sm *ServerManager) AddServer() (*ServerInfo, error) {
 	ServerInfo := &ServerInfo{
 		Port:      port,
 		URL:       fmt.Sprintf("http://localhost:%d", port),
+		Pool:      pool,
 		Status:    "starting",
 		StartedAt: time.Now(),
 		server: &http.Server{
@@ -117,8 +121,11 @@ func (sm *ServerManager) AddServer() (*ServerInfo, error) {
 
 	go func() {
 		backend := &config.Backend{
-			Name: "abc",
-			URL:  fmt.Sprintf("http://localhost:%d", port),
+			Name:    "abc",
+			URL:     fmt.Sprintf("http://localhost:%d", port),
+			Pool:    pool,
+			Weight:  1,
+			Enabled: true,
 			// Status: "healthy",
 		}
 
@@ -293,7 +300,7 @@ func (sm *ServerManager) handleAddServer(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	server, err := sm.AddServer()
+	server, err := sm.AddServer(r.URL.Query().Get("pool"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -388,7 +395,7 @@ func main() {
 			return
 		}
 		fmt.Fprintf(w, `Server Manager API:
-		POST   /servers/add           - Add a new server
+		POST   /servers/add?pool=X    - Add a new server, optionally into pool X
 		DELETE /servers/remove?port=X - Remove server by port
 		GET    /servers/list          - List all servers
 		GET    /servers/get?port=X    - Get server info by port
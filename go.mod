@@ -3,12 +3,15 @@ module github.com/shubhamojha1/heimdall
 go 1.23
 
 require (
-	github.com/fsnotify/fsnotify v1.8.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/joho/godotenv v1.5.1
+	google.golang.org/grpc v1.70.0
+)
+
+require (
 	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250127172529-29210b9bc287 // indirect
-	google.golang.org/grpc v1.70.0 // indirect
 	google.golang.org/protobuf v1.36.4 // indirect
 )
@@ -1,27 +1,134 @@
 package algorithms
 
-// look up server list from service registry
-// do round robin of requests
-// use a global counter/pointer to keep track of which server to hit
-// do modulus for going from last to first and so on
+// a Balancer looks at the backends the service registry currently reports as
+// healthy and picks the one the next request or connection should go to.
+// the load balancer builds one Balancer per configuration through New.
 import (
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"sync"
+	"time"
 
-	"github.com/shubhamojha1/heimdall/internal/registry"
+	"github.com/shubhamojha1/heimdall/internal/config"
+	"github.com/shubhamojha1/heimdall/internal/metrics"
 )
 
-// const (
-// 	Loadbalancer = load_balancer.LoadBalancer
-// )
+var ErrNoBackend = errors.New("no healthy backend available")
 
-// func main() {
+// Request is what a Balancer knows about the traffic being routed.
+type Request struct {
+	ClientIP string        // client address without the port
+	HTTP     *http.Request // nil on the layer 4 path
+}
+
+func NewHTTPRequest(r *http.Request) *Request {
+	return &Request{
+		ClientIP: hostOnly(r.RemoteAddr),
+		HTTP:     r,
+	}
+}
+
+func NewConnRequest(conn net.Conn) *Request {
+	return &Request{
+		ClientIP: hostOnly(conn.RemoteAddr().String()),
+	}
+}
+
+// Balancer picks a backend from a snapshot of healthy backends. Pick is called
+// concurrently and must not hold on to the slice after returning.
+type Balancer interface {
+	Pick(backends []*config.Backend, req *Request) (*config.Backend, error)
+}
+
+// LatencyObserver is implemented by balancers that learn from the latency of
+// the traffic they route. The proxy reports each stage as it is reached.
+type LatencyObserver interface {
+	ObserveLatency(b *config.Backend, measure string, latency time.Duration)
+}
+
+// FailureObserver is implemented by balancers that learn from requests or
+// connections that failed on a backend.
+type FailureObserver interface {
+	ObserveFailure(b *config.Backend)
+}
+
+// MembershipObserver is implemented by balancers that keep state per
+// backend. The load balancer reports every backend in the pool, serving or
+// not, after each registry sync; the slice must not be kept.
+type MembershipObserver interface {
+	ObserveBackends(backends []*config.Backend)
+}
 
-// 	// get list of backends from service registry
-// 	backends := load_balancer.LoadBalancer.ServiceRegistry.Backends
+// ResponseDecorator is implemented by balancers that need to annotate the
+// response to a request they routed, e.g. to set an affinity cookie.
+type ResponseDecorator interface {
+	DecorateResponse(b *config.Backend, req *Request, header http.Header)
+}
+
+// MetricsReporter is implemented by balancers that keep state worth
+// exporting with the load balancer's metrics.
+type MetricsReporter interface {
+	ReportMetrics(m *metrics.LoadBalancerMetrics)
+}
+
+// Factory builds a Balancer for the given configuration.
+type Factory func(cfg *config.Config) (Balancer, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[config.Algorithm]Factory{
+		config.AlgorithmRoundRobin:         newRoundRobin,
+		config.AlgorithmWeightedRoundRobin: newWeightedRoundRobin,
+		config.AlgorithmLeastConnections:   newLeastConnections,
+		config.AlgorithmStickyRoundRobin:   newStickyRoundRobin,
+		config.AlgorithmMaglev:             newMaglev,
+		config.AlgorithmURLHash:            newURLHash,
+		config.AlgorithmCookieBased:        newCookieBased,
+		config.AlgorithmContentBased:       newContentBased,
+		config.AlgorithmIPHash:             newIPHash,
+		config.AlgorithmLeastTime:          newLeastTime,
+		config.AlgorithmP2C:                newP2C,
+	}
+)
+
+// Register adds a custom algorithm, usable on the given layers, so that
+// configs naming it pass validation and New can build it. Registering a name
+// twice replaces the earlier factory.
+func Register(name config.Algorithm, factory Factory, layers ...config.Layer) {
+	mu.Lock()
+	factories[name] = factory
+	mu.Unlock()
 
-// }
+	config.RegisterAlgorithm(name, layers...)
+}
+
+// New builds the Balancer for cfg.Algorithm.
+func New(cfg *config.Config) (Balancer, error) {
+	mu.RLock()
+	factory, ok := factories[cfg.Algorithm]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no balancer registered for algorithm %s", cfg.Algorithm)
+	}
+	return factory(cfg)
+}
+
+func hostOnly(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
 
-func handleConnectionRoundRobin(lb *registry.ServiceRegistry, clientConn net.Conn) {
-	backends := registry.Backends
-	// backends :=
+func findByURL(backends []*config.Backend, url string) *config.Backend {
+	for _, b := range backends {
+		if b.URL == url {
+			return b
+		}
+	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package algorithms
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// number of virtual nodes each backend gets on the ring
+const ringReplicas = 160
+
+// hashRing is a consistent hash ring over a backend snapshot. Every backend
+// owns ringReplicas points on the ring and a key belongs to the first point
+// at or after its hash, so adding or removing one of N backends only moves
+// the keys next to that backend's points, about 1/N of them.
+//
+// A ring is immutable once built.
+type hashRing struct {
+	members []string    // backend URLs, in snapshot order
+	points  []ringPoint // sorted by hash
+}
+
+type ringPoint struct {
+	hash   uint64
+	member int // index into members
+}
+
+func newHashRing(backends []*config.Backend) *hashRing {
+	r := &hashRing{
+		members: make([]string, len(backends)),
+		points:  make([]ringPoint, 0, len(backends)*ringReplicas),
+	}
+
+	for i, b := range backends {
+		r.members[i] = b.URL
+		for v := 0; v < ringReplicas; v++ {
+			r.points = append(r.points, ringPoint{
+				hash:   hashKey(b.URL + "#" + strconv.Itoa(v)),
+				member: i,
+			})
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i].hash < r.points[j].hash
+	})
+	return r
+}
+
+// matches reports whether the ring was built from this exact snapshot, in
+// which case member indexes can be used on backends directly.
+func (r *hashRing) matches(backends []*config.Backend) bool {
+	if len(r.members) != len(backends) {
+		return false
+	}
+	for i, b := range backends {
+		if r.members[i] != b.URL {
+			return false
+		}
+	}
+	return true
+}
+
+// membership identifies a backend snapshot, order included.
+func membership(backends []*config.Backend) string {
+	var sb strings.Builder
+	for _, b := range backends {
+		sb.WriteString(b.URL)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// search returns the index of the point owning hash.
+func (r *hashRing) search(hash uint64) int {
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+	if i == len(r.points) {
+		return 0
+	}
+	return i
+}
+
+// hashKey is FNV-1a followed by a 64 bit finalizer, which spreads keys that
+// only differ in a trailing counter across the whole ring.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
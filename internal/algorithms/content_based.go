@@ -0,0 +1,36 @@
+package algorithms
+
+import (
+	"strings"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// contentBased keeps requests for the same host and top level path on the
+// same backend, so each backend's caches see a stable slice of the content.
+// Which pool a request goes to in the first place is decided by the routing
+// rules in l7_settings.routing.
+type contentBased struct {
+	hash hashBalancer
+}
+
+func newContentBased(cfg *config.Config) (Balancer, error) {
+	return &contentBased{hash: hashBalancer{key: contentKey}}, nil
+}
+
+func (c *contentBased) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	return c.hash.Pick(backends, req)
+}
+
+// contentKey is the request host plus the first segment of its path.
+func contentKey(req *Request) string {
+	if req.HTTP == nil {
+		return ""
+	}
+
+	path := strings.TrimPrefix(req.HTTP.URL.Path, "/")
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		path = path[:i]
+	}
+	return req.HTTP.Host + "/" + path
+}
@@ -0,0 +1,125 @@
+package algorithms
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+const defaultCookieName = "heimdall_backend"
+
+// cookieBased pins a client to a backend with an affinity cookie. The cookie
+// holds an opaque backend ID signed with HMAC-SHA256, so clients can neither
+// read backend addresses from it nor forge one. A request with a valid
+// cookie goes to that backend while it is healthy; otherwise a backend is
+// picked round robin and a new cookie is issued with the response.
+type cookieBased struct {
+	secret   []byte
+	template http.Cookie // name and attributes of issued cookies
+	fallback roundRobin
+}
+
+func newCookieBased(cfg *config.Config) (Balancer, error) {
+	sticky := cfg.L7().Sticky
+
+	c := &cookieBased{
+		template: http.Cookie{
+			Name:     sticky.CookieName,
+			Path:     sticky.CookiePath,
+			Domain:   sticky.CookieDomain,
+			MaxAge:   int(sticky.CookieTTL.Seconds()),
+			Secure:   sticky.CookieSecure,
+			HttpOnly: true,
+		},
+	}
+	if c.template.Name == "" {
+		c.template.Name = defaultCookieName
+	}
+	if c.template.Path == "" {
+		c.template.Path = "/"
+	}
+
+	switch strings.ToLower(sticky.CookieSameSite) {
+	case "":
+	case "lax":
+		c.template.SameSite = http.SameSiteLaxMode
+	case "strict":
+		c.template.SameSite = http.SameSiteStrictMode
+	case "none":
+		c.template.SameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unsupported sticky cookie_same_site: %s", sticky.CookieSameSite)
+	}
+
+	if sticky.CookieSecret != "" {
+		c.secret = []byte(sticky.CookieSecret)
+	} else {
+		c.secret = make([]byte, 32)
+		if _, err := rand.Read(c.secret); err != nil {
+			return nil, fmt.Errorf("failed to generate cookie secret: %w", err)
+		}
+		log.Println("No sticky cookie_secret configured, affinity cookies will not survive a restart")
+	}
+	return c, nil
+}
+
+func (c *cookieBased) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	if id, ok := c.cookieBackendID(req); ok {
+		for _, b := range backends {
+			if backendID(b) == id {
+				return b, nil
+			}
+		}
+	}
+	return c.fallback.Pick(backends, req)
+}
+
+// DecorateResponse issues a cookie unless the request already carried a
+// valid one for b.
+func (c *cookieBased) DecorateResponse(b *config.Backend, req *Request, header http.Header) {
+	id := backendID(b)
+	if current, ok := c.cookieBackendID(req); ok && current == id {
+		return
+	}
+
+	cookie := c.template
+	cookie.Value = id + "." + c.sign(id)
+	header.Add("Set-Cookie", cookie.String())
+}
+
+// cookieBackendID returns the backend ID from the request's affinity cookie
+// if the cookie is present and correctly signed.
+func (c *cookieBased) cookieBackendID(req *Request) (string, bool) {
+	if req.HTTP == nil {
+		return "", false
+	}
+	cookie, err := req.HTTP.Cookie(c.template.Name)
+	if err != nil {
+		return "", false
+	}
+
+	id, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(c.sign(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+func (c *cookieBased) sign(id string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// backendID is a stable, opaque name for a backend.
+func backendID(b *config.Backend) string {
+	return strconv.FormatUint(hashKey(b.URL), 36)
+}
@@ -0,0 +1,136 @@
+package algorithms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func newTestCookieBased(t *testing.T, secret string) *cookieBased {
+	t.Helper()
+
+	var l7 config.L7Settings
+	l7.Sticky = config.StickySettings{
+		Enabled:        true,
+		CookieName:     "lb",
+		CookieTTL:      time.Hour,
+		CookieSecure:   true,
+		CookieSameSite: "strict",
+		CookieDomain:   "example.com",
+		CookieSecret:   secret,
+	}
+	balancer, err := newCookieBased(&config.Config{LayerConfig: l7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balancer.(*cookieBased)
+}
+
+func cookieRequest(cookies ...*http.Cookie) *Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	return NewHTTPRequest(r)
+}
+
+// issued returns the affinity cookie c sets for b, or nil.
+func issued(c *cookieBased, b *config.Backend, req *Request) *http.Cookie {
+	header := make(http.Header)
+	c.DecorateResponse(b, req, header)
+	for _, cookie := range (&http.Response{Header: header}).Cookies() {
+		if cookie.Name == c.template.Name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestCookieBasedAffinity(t *testing.T) {
+	c := newTestCookieBased(t, "secret")
+	backends := weightedBackends(1, 1, 1)
+
+	req := cookieRequest()
+	b, _ := c.Pick(backends, req)
+	cookie := issued(c, b, req)
+	if cookie == nil {
+		t.Fatal("no cookie issued for a request without one")
+	}
+
+	if cookie.MaxAge != 3600 || !cookie.Secure || !cookie.HttpOnly ||
+		cookie.SameSite != http.SameSiteStrictMode || cookie.Domain != "example.com" || cookie.Path != "/" {
+		t.Errorf("cookie %q is missing configured attributes", cookie.String())
+	}
+
+	// a valid cookie keeps the client on its backend, and isn't reissued
+	for i := 0; i < 5; i++ {
+		req := cookieRequest(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		if got, _ := c.Pick(backends, req); got != b {
+			t.Fatalf("pick %d went to %s, want %s", i, got.URL, b.URL)
+		}
+		if issued(c, b, req) != nil {
+			t.Fatal("cookie reissued for the backend it names")
+		}
+	}
+}
+
+func TestCookieBasedRejectsForgedCookies(t *testing.T) {
+	c := newTestCookieBased(t, "secret")
+	backends := weightedBackends(1, 1, 1)
+
+	valid := issued(c, backends[0], cookieRequest())
+	other := newTestCookieBased(t, "other secret")
+	forged := issued(other, backends[1], cookieRequest())
+	_, sig, _ := strings.Cut(valid.Value, ".")
+	tampered := "A" + sig[1:]
+	if tampered == sig {
+		tampered = "B" + sig[1:]
+	}
+
+	for name, value := range map[string]string{
+		"another secret":  forged.Value,
+		"swapped id":      backendID(backends[1]) + "." + sig,
+		"tampered sig":    backendID(backends[0]) + "." + tampered,
+		"unsigned":        backendID(backends[0]),
+		"backend address": backends[0].URL,
+	} {
+		req := cookieRequest(&http.Cookie{Name: "lb", Value: value})
+		if id, ok := c.cookieBackendID(req); ok {
+			t.Errorf("%s: cookie accepted for backend %s", name, id)
+		}
+		if issued(c, backends[2], req) == nil {
+			t.Errorf("%s: no fresh cookie issued in place of the rejected one", name)
+		}
+	}
+}
+
+func TestCookieBasedBackendGone(t *testing.T) {
+	c := newTestCookieBased(t, "secret")
+	backends := weightedBackends(1, 1, 1)
+
+	cookie := issued(c, backends[0], cookieRequest())
+	req := cookieRequest(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+
+	// the cookie's backend left the pool, so the client is picked a new
+	// one and given a cookie for it
+	b, err := c.Pick(backends[1:], req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == backends[0] {
+		t.Fatal("picked a backend that isn't a candidate")
+	}
+	reissued := issued(c, b, req)
+	if reissued == nil {
+		t.Fatal("no cookie issued for the new backend")
+	}
+
+	next := cookieRequest(&http.Cookie{Name: reissued.Name, Value: reissued.Value})
+	if got, _ := c.Pick(backends, next); got != b {
+		t.Errorf("reissued cookie sent the client to %s, want %s", got.URL, b.URL)
+	}
+}
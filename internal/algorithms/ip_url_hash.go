@@ -0,0 +1,172 @@
+package algorithms
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// hashBalancer maps a key derived from the request onto a consistent hash
+// ring of the healthy backends. A new ring is built whenever the backend set
+// changes. The last few rings are kept, since with zone weights consecutive
+// picks can alternate between a handful of backend sets.
+//
+// With bounded loads enabled, a backend whose active connections have reached
+// loadFactor times the mean is skipped and the key moves on to the next
+// backend along the ring (consistent hashing with bounded loads, Mirrokni et
+// al.). Most keys still stay put, and no backend goes above the bound.
+type hashBalancer struct {
+	key        func(req *Request) string
+	loadFactor float64 // 0 disables bounded loads
+
+	ring  atomic.Pointer[hashRing] // most recently used ring
+	mu    sync.Mutex
+	rings map[string]*hashRing // recent rings by membership
+}
+
+// number of rings a hashBalancer keeps around
+const maxCachedRings = 16
+
+const defaultLoadFactor = 1.25
+
+func newIPHash(cfg *config.Config) (Balancer, error) {
+	return newHashBalancer(cfg, clientIPKey)
+}
+
+func newURLHash(cfg *config.Config) (Balancer, error) {
+	return newHashBalancer(cfg, urlKey)
+}
+
+func newHashBalancer(cfg *config.Config, fallback func(req *Request) string) (Balancer, error) {
+	key, err := stickyKey(cfg, fallback)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hashBalancer{key: key}
+	if cfg.Hash.BoundedLoad {
+		h.loadFactor = cfg.Hash.LoadFactor
+		if h.loadFactor == 0 {
+			h.loadFactor = defaultLoadFactor
+		}
+		if h.loadFactor < 1 {
+			return nil, fmt.Errorf("hash load_factor must be at least 1, got %v", h.loadFactor)
+		}
+	}
+	return h, nil
+}
+
+func (h *hashBalancer) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+
+	key := h.key(req)
+	if key == "" {
+		// requests without the header or parameter still spread by client
+		key = req.ClientIP
+	}
+
+	ring := h.ringFor(backends)
+	start := ring.search(hashKey(key))
+	if h.loadFactor == 0 {
+		return backends[ring.points[start].member], nil
+	}
+
+	var total int64
+	for _, b := range backends {
+		total += atomic.LoadInt64(&b.Metrics.ActiveConnections)
+	}
+	// counting the new connection in the mean guarantees a backend has room
+	capacity := int64(math.Ceil(h.loadFactor * float64(total+1) / float64(len(backends))))
+
+	for i := range ring.points {
+		b := backends[ring.points[(start+i)%len(ring.points)].member]
+		if atomic.LoadInt64(&b.Metrics.ActiveConnections) < capacity {
+			return b, nil
+		}
+	}
+	// loads moved under us, fall back to the plain ring owner
+	return backends[ring.points[start].member], nil
+}
+
+func (h *hashBalancer) ringFor(backends []*config.Backend) *hashRing {
+	if ring := h.ring.Load(); ring != nil && ring.matches(backends) {
+		return ring
+	}
+
+	key := membership(backends)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ring, ok := h.rings[key]
+	if !ok {
+		if h.rings == nil || len(h.rings) >= maxCachedRings {
+			h.rings = make(map[string]*hashRing)
+		}
+		ring = newHashRing(backends)
+		h.rings[key] = ring
+	}
+	h.ring.Store(ring)
+	return ring
+}
+
+// stickyKey returns the key function selected by l7_settings.sticky, or
+// fallback when sticky hashing is not configured.
+func stickyKey(cfg *config.Config, fallback func(req *Request) string) (func(req *Request) string, error) {
+	sticky := cfg.L7().Sticky
+	if !sticky.Enabled || sticky.HashMethod == "" {
+		return fallback, nil
+	}
+
+	switch sticky.HashMethod {
+	case config.HashMethodIP:
+		return clientIPKey, nil
+	case config.HashMethodURL:
+		return urlKey, nil
+	case config.HashMethodHeader:
+		if sticky.HashKey == "" {
+			return nil, fmt.Errorf("sticky hash_method %q needs a hash_key", sticky.HashMethod)
+		}
+		return headerKey(sticky.HashKey), nil
+	case config.HashMethodQuery:
+		if sticky.HashKey == "" {
+			return nil, fmt.Errorf("sticky hash_method %q needs a hash_key", sticky.HashMethod)
+		}
+		return queryKey(sticky.HashKey), nil
+	}
+	return nil, fmt.Errorf("unsupported sticky hash_method: %s", sticky.HashMethod)
+}
+
+func clientIPKey(req *Request) string {
+	return req.ClientIP
+}
+
+func urlKey(req *Request) string {
+	if req.HTTP == nil {
+		return ""
+	}
+	return req.HTTP.URL.RequestURI()
+}
+
+func headerKey(name string) func(req *Request) string {
+	return func(req *Request) string {
+		if req.HTTP == nil {
+			return ""
+		}
+		return req.HTTP.Header.Get(name)
+	}
+}
+
+func queryKey(param string) func(req *Request) string {
+	return func(req *Request) string {
+		if req.HTTP == nil {
+			return ""
+		}
+		return req.HTTP.URL.Query().Get(param)
+	}
+}
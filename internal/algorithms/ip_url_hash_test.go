@@ -0,0 +1,137 @@
+package algorithms
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// assign picks a backend for each of n client IPs.
+func assign(t *testing.T, balancer Balancer, backends []*config.Backend, n int) map[string]*config.Backend {
+	t.Helper()
+
+	assigned := make(map[string]*config.Backend, n)
+	for i := 0; i < n; i++ {
+		ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		b, err := balancer.Pick(backends, &Request{ClientIP: ip})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assigned[ip] = b
+	}
+	return assigned
+}
+
+func TestIPHashRemapFraction(t *testing.T) {
+	const n, keys = 10, 20000
+
+	balancer, err := newIPHash(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := weightedBackends(1, 1, 1, 1, 1, 1, 1, 1, 1, 1)
+	before := assign(t, balancer, backends, keys)
+
+	// removing a backend only moves the keys it held
+	removed := backends[3]
+	fewer := append(append([]*config.Backend{}, backends[:3]...), backends[4:]...)
+	moved := 0
+	for ip, b := range assign(t, balancer, fewer, keys) {
+		if b == before[ip] {
+			continue
+		}
+		if before[ip] != removed {
+			t.Fatalf("client %s moved from %s, which is still in the pool", ip, before[ip].URL)
+		}
+		moved++
+	}
+	if frac := float64(moved) / keys; frac < 0.5/n || frac > 1.5/n {
+		t.Errorf("removing 1 of %d backends moved %.3f of keys, want about %.3f", n, frac, 1.0/n)
+	}
+
+	// adding one only moves keys onto it
+	added := &config.Backend{Name: "k", URL: "http://backend-10", Weight: 1}
+	more := append(append([]*config.Backend{}, backends...), added)
+	moved = 0
+	for ip, b := range assign(t, balancer, more, keys) {
+		if b == before[ip] {
+			continue
+		}
+		if b != added {
+			t.Fatalf("client %s moved from %s to %s, not to the new backend", ip, before[ip].URL, b.URL)
+		}
+		moved++
+	}
+	if frac := float64(moved) / keys; frac < 0.5/(n+1) || frac > 1.5/(n+1) {
+		t.Errorf("adding an 11th backend moved %.3f of keys, want about %.3f", frac, 1.0/(n+1))
+	}
+}
+
+func TestHashMethodKeys(t *testing.T) {
+	tests := []struct {
+		method, key string
+		header      bool // the key is a header rather than a query parameter
+	}{
+		{method: config.HashMethodHeader, key: "X-User", header: true},
+		{method: config.HashMethodQuery, key: "user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			var l7 config.L7Settings
+			l7.Sticky = config.StickySettings{Enabled: true, HashMethod: tt.method, HashKey: tt.key}
+			balancer, err := newURLHash(&config.Config{LayerConfig: l7})
+			if err != nil {
+				t.Fatal(err)
+			}
+			backends := weightedBackends(1, 1, 1, 1, 1)
+
+			request := func(user, clientIP, path string) *Request {
+				target := path
+				if !tt.header && user != "" {
+					target += "?" + tt.key + "=" + user
+				}
+				r := httptest.NewRequest("GET", target, nil)
+				if tt.header && user != "" {
+					r.Header.Set(tt.key, user)
+				}
+				return &Request{ClientIP: clientIP, HTTP: r}
+			}
+
+			// the same key sticks to a backend whatever the client and path,
+			// and different keys spread over the backends
+			seen := make(map[*config.Backend]bool)
+			for i := 0; i < 50; i++ {
+				user := fmt.Sprintf("user-%d", i)
+				want, _ := balancer.Pick(backends, request(user, "10.0.0.1", "/"))
+				got, _ := balancer.Pick(backends, request(user, fmt.Sprintf("10.0.1.%d", i), fmt.Sprintf("/page/%d", i)))
+				if got != want {
+					t.Fatalf("%s moved from %s to %s with another client and path", user, want.URL, got.URL)
+				}
+				seen[want] = true
+			}
+			if len(seen) < 2 {
+				t.Errorf("50 keys all went to one backend")
+			}
+
+			// requests without the key fall back to the client IP
+			for i := 0; i < 10; i++ {
+				ip := fmt.Sprintf("10.0.2.%d", i)
+				want, _ := balancer.Pick(backends, &Request{ClientIP: ip})
+				got, _ := balancer.Pick(backends, request("", ip, fmt.Sprintf("/page/%d", i)))
+				if got != want {
+					t.Fatalf("request from %s without a key went to %s, want %s", ip, got.URL, want.URL)
+				}
+			}
+		})
+	}
+
+	// without a key name the method is rejected
+	var l7 config.L7Settings
+	l7.Sticky = config.StickySettings{Enabled: true, HashMethod: config.HashMethodHeader}
+	if _, err := newIPHash(&config.Config{LayerConfig: l7}); err == nil {
+		t.Error("header hash_method accepted without a hash_key")
+	}
+}
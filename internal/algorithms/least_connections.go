@@ -0,0 +1,46 @@
+package algorithms
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// leastConnections picks the backend with the fewest in-flight requests or
+// connections relative to its weight. The proxy keeps
+// Metrics.ActiveConnections up to date with atomic counters. Ties are broken
+// at random so the first backend in the snapshot doesn't take every tie.
+type leastConnections struct{}
+
+func newLeastConnections(cfg *config.Config) (Balancer, error) {
+	return leastConnections{}, nil
+}
+
+func (leastConnections) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	var best *config.Backend
+	var bestConns, bestWeight float64
+	ties := 0
+	for _, b := range backends {
+		conns := float64(atomic.LoadInt64(&b.Metrics.ActiveConnections))
+		weight := b.EffectiveWeight()
+
+		// conns/weight < bestConns/bestWeight, without dividing
+		switch {
+		case best == nil || conns*bestWeight < bestConns*weight:
+			best, bestConns, bestWeight = b, conns, weight
+			ties = 1
+		case conns*bestWeight == bestConns*weight:
+			// reservoir sampling keeps each tied backend equally likely
+			ties++
+			if rand.IntN(ties) == 0 {
+				best, bestConns, bestWeight = b, conns, weight
+			}
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoBackend
+	}
+	return best, nil
+}
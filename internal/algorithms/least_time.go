@@ -0,0 +1,204 @@
+package algorithms
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+const (
+	defaultHalfLife = 10 * time.Second
+
+	// a failed request counts as a sample this many times the pool's mean
+	// latency, or defaultFailurePenalty before anything has been measured
+	failurePenaltyFactor  = 2
+	defaultFailurePenalty = time.Second
+)
+
+// leastTime scores every backend by its peak EWMA latency times its
+// in-flight count plus one, and picks the lowest score. Backends nobody has
+// measured yet are taken to be as fast as the pool's mean, and failures
+// count as slow samples.
+type leastTime struct {
+	measure string
+	latency *peakEWMA
+}
+
+func newLeastTime(cfg *config.Config) (Balancer, error) {
+	measure, latency, err := latencySettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &leastTime{measure: measure, latency: latency}, nil
+}
+
+// latencySettings reads the least_time settings shared by every balancer
+// that tracks latency.
+func latencySettings(cfg *config.Config) (string, *peakEWMA, error) {
+	measure := cfg.LeastTime.Measure
+	switch measure {
+	case "":
+		measure = config.MeasureFirstByte
+	case config.MeasureFirstByte, config.MeasureLastByte:
+	default:
+		return "", nil, fmt.Errorf("unsupported least_time measure: %s", measure)
+	}
+
+	latency, err := newPeakEWMA(cfg.LeastTime.HalfLife)
+	if err != nil {
+		return "", nil, err
+	}
+	return measure, latency, nil
+}
+
+func (l *leastTime) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	var best *config.Backend
+	var bestScore float64
+	ties := 0
+	for _, b := range backends {
+		score := l.latency.score(b)
+		switch {
+		case best == nil || score < bestScore:
+			best, bestScore = b, score
+			ties = 1
+		case score == bestScore:
+			ties++
+			if rand.IntN(ties) == 0 {
+				best = b
+			}
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoBackend
+	}
+	return best, nil
+}
+
+func (l *leastTime) ObserveLatency(b *config.Backend, measure string, latency time.Duration) {
+	if measure == l.measure {
+		l.latency.observe(b, latency)
+	}
+}
+
+func (l *leastTime) ObserveFailure(b *config.Backend) {
+	l.latency.observeFailure(b)
+}
+
+func (l *leastTime) ObserveBackends(backends []*config.Backend) {
+	l.latency.prune(backends)
+}
+
+// peakEWMA is an exponentially weighted moving average of latency that
+// jumps straight up to any sample above it and decays by half every
+// halfLife, so a backend that suddenly slows down is penalised at once and
+// recovers gradually. The current average is published to
+// Metrics.ResponseTime and the latest sample to Metrics.LastResponseTime.
+type peakEWMA struct {
+	tau float64 // decay constant in nanoseconds
+
+	mu      sync.Mutex
+	samples map[string]ewmaSample // by backend URL
+	sum     float64               // of the averages in samples
+
+	// mean of the averages in samples in nanoseconds, updated atomically;
+	// backends without a sample are scored as if they had it
+	mean int64
+}
+
+type ewmaSample struct {
+	at  time.Time // of the last sample
+	avg float64
+}
+
+func newPeakEWMA(halfLife time.Duration) (*peakEWMA, error) {
+	if halfLife == 0 {
+		halfLife = defaultHalfLife
+	}
+	if halfLife < 0 {
+		return nil, fmt.Errorf("half_life must be positive, got %v", halfLife)
+	}
+
+	return &peakEWMA{
+		tau:     float64(halfLife) / math.Ln2,
+		samples: make(map[string]ewmaSample),
+	}, nil
+}
+
+func (p *peakEWMA) observe(b *config.Backend, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.add(b, float64(latency))
+	atomic.StoreInt64((*int64)(&b.Metrics.LastResponseTime), int64(latency))
+}
+
+// observeFailure adds a penalty sample for a failed request: the pool's mean
+// latency times failurePenaltyFactor, or b's own average if that's higher.
+func (p *peakEWMA) observeFailure(b *config.Backend) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	penalty := failurePenaltyFactor * float64(atomic.LoadInt64(&p.mean))
+	if penalty == 0 {
+		penalty = float64(defaultFailurePenalty)
+	}
+	p.add(b, max(penalty, p.samples[b.URL].avg))
+}
+
+// add folds sample into b's average. Callers hold p.mu.
+func (p *peakEWMA) add(b *config.Backend, sample float64) {
+	now := time.Now()
+	prev, seen := p.samples[b.URL]
+
+	avg := sample
+	if seen && sample <= prev.avg {
+		w := math.Exp(-float64(now.Sub(prev.at)) / p.tau)
+		avg = prev.avg*w + sample*(1-w)
+	}
+
+	p.samples[b.URL] = ewmaSample{at: now, avg: avg}
+	p.sum += avg - prev.avg
+	atomic.StoreInt64(&p.mean, int64(p.sum/float64(len(p.samples))))
+	atomic.StoreInt64((*int64)(&b.Metrics.ResponseTime), int64(avg))
+}
+
+// prune drops the samples of backends that left the pool.
+func (p *peakEWMA) prune(backends []*config.Backend) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		current[b.URL] = true
+	}
+	for url, s := range p.samples {
+		if !current[url] {
+			p.sum -= s.avg
+			delete(p.samples, url)
+		}
+	}
+
+	mean := 0.0
+	if len(p.samples) > 0 {
+		mean = p.sum / float64(len(p.samples))
+	}
+	atomic.StoreInt64(&p.mean, int64(mean))
+}
+
+// score is the expected latency of one more request on b, weighted.
+func (p *peakEWMA) score(b *config.Backend) float64 {
+	avg := float64(atomic.LoadInt64((*int64)(&b.Metrics.ResponseTime)))
+	if avg == 0 {
+		// not measured yet; at least 1ns, so that in-flight requests count
+		// before anything has been measured
+		avg = max(1, float64(atomic.LoadInt64(&p.mean)))
+	}
+	inFlight := float64(atomic.LoadInt64(&b.Metrics.ActiveConnections))
+	return avg * (inFlight + 1) / b.EffectiveWeight()
+}
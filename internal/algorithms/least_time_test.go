@@ -0,0 +1,44 @@
+package algorithms
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func TestLeastTimeUnmeasuredBackend(t *testing.T) {
+	balancer, err := newLeastTime(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := balancer.(*leastTime)
+
+	backends := weightedBackends(1, 1, 1)
+	l.ObserveLatency(backends[0], config.MeasureFirstByte, 10*time.Millisecond)
+	l.ObserveLatency(backends[1], config.MeasureFirstByte, 10*time.Millisecond)
+
+	// the new backend is taken to be as fast as the others, so it stops
+	// winning once it has more requests in flight
+	backends[2].Metrics.ActiveConnections = 5
+	for i := 0; i < 10; i++ {
+		b, _ := l.Pick(backends, &Request{})
+		if b == backends[2] {
+			t.Fatal("picked the unmeasured backend with 5 requests in flight")
+		}
+	}
+
+	// a failure makes a backend look slower than the rest
+	l.ObserveFailure(backends[0])
+	for i := 0; i < 10; i++ {
+		b, _ := l.Pick(backends[:2], &Request{})
+		if b == backends[0] {
+			t.Fatal("picked the backend that just failed")
+		}
+	}
+
+	l.ObserveBackends(backends[1:])
+	if n := len(l.latency.samples); n != 1 {
+		t.Errorf("%d backends sampled after one left, want 1", n)
+	}
+}
@@ -0,0 +1,205 @@
+package algorithms
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+const defaultMaglevTableSize = 65537
+
+// maglev is Google's Maglev consistent hashing (Eisenbud et al., NSDI 2016).
+// Every backend walks its own permutation of a prime sized lookup table and
+// the backends take turns claiming their next free slot, which gives each
+// backend an almost equal share of the table. A pick is a single table
+// lookup, and a change in the backend set only reassigns a small share of
+// the slots.
+//
+// The table is built from every backend registered in the pool, in the
+// background whenever the registry reports a change, so neither picks nor
+// the registry sync wait for a build. Until the pool's first table is ready
+// picks hash the key over the candidates instead. A pick walks on from the
+// key's slot past backends that aren't candidates for it, e.g. because they
+// are at MaxConns or their circuit is open, so those keys come back to their
+// backend as soon as it takes traffic again.
+type maglev struct {
+	size uint64
+
+	mu      sync.Mutex     // guards members and gen
+	members []string       // backend URLs of the latest report, sorted
+	gen     uint64         // bumped by every report that changes members
+	builds  sync.WaitGroup // tables being built
+	table   atomic.Pointer[maglevTable]
+}
+
+type maglevTable struct {
+	members []string         // backend URLs, sorted
+	index   map[string]int32 // backend URL -> index into members
+	lookup  []int32          // slot -> index into members
+}
+
+func newMaglev(cfg *config.Config) (Balancer, error) {
+	size := cfg.L4().Maglev.TableSize
+	if size == 0 {
+		size = defaultMaglevTableSize
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("maglev table_size must be positive, got %d", size)
+	}
+
+	return &maglev{size: nextPrime(uint64(size))}, nil
+}
+
+func (m *maglev) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+
+	key := hashKey(req.ClientIP)
+	table := m.table.Load()
+	if table == nil {
+		return backends[key%uint64(len(backends))], nil
+	}
+
+	// the candidates by their index into members, so each step of the walk
+	// is a single lookup
+	candidates := make([]*config.Backend, len(table.members))
+	known := false
+	for _, b := range backends {
+		if i, ok := table.index[b.URL]; ok {
+			candidates[i] = b
+			known = true
+		}
+	}
+	if !known {
+		// none of them made it into the table yet
+		return backends[key%uint64(len(backends))], nil
+	}
+
+	slot := key % m.size
+	for i := uint64(0); ; i++ {
+		if b := candidates[table.lookup[(slot+i)%m.size]]; b != nil {
+			return b, nil
+		}
+	}
+}
+
+// ObserveBackends starts building the table for the pool's backends, unless
+// they are the ones last reported.
+func (m *maglev) ObserveBackends(backends []*config.Backend) {
+	// the order backends are reported in mustn't change the table
+	members := make([]string, len(backends))
+	for i, b := range backends {
+		members[i] = b.URL
+	}
+	slices.Sort(members)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.Equal(members, m.members) {
+		return
+	}
+	m.members = members
+	m.gen++
+	if len(members) == 0 {
+		m.table.Store(nil)
+		return
+	}
+
+	// the caller holds the lock picks wait for, and a large table takes a
+	// while to build
+	gen := m.gen
+	m.builds.Add(1)
+	go func() {
+		defer m.builds.Done()
+		m.build(members, gen)
+	}()
+}
+
+// build builds and publishes the table for members, unless a later report
+// replaced them in the meantime.
+func (m *maglev) build(members []string, gen uint64) {
+	if m.stale(gen) {
+		return
+	}
+	table := newMaglevTable(members, m.size)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if gen == m.gen {
+		m.table.Store(table)
+	}
+}
+
+func (m *maglev) stale(gen uint64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return gen != m.gen
+}
+
+// newMaglevTable builds the table for members, which must be sorted.
+func newMaglevTable(members []string, size uint64) *maglevTable {
+	n := len(members)
+	table := &maglevTable{
+		members: members,
+		index:   make(map[string]int32, n),
+		lookup:  make([]int32, size),
+	}
+
+	offset := make([]uint64, n)
+	skip := make([]uint64, n)
+	next := make([]uint64, n)
+	for i, url := range table.members {
+		table.index[url] = int32(i)
+		offset[i] = hashKey(url+"#offset") % size
+		skip[i] = hashKey(url+"#skip")%(size-1) + 1
+	}
+
+	for i := range table.lookup {
+		table.lookup[i] = -1
+	}
+
+	for filled := uint64(0); ; {
+		for i := 0; i < n; i++ {
+			slot := (offset[i] + next[i]*skip[i]) % size
+			for table.lookup[slot] >= 0 {
+				next[i]++
+				slot = (offset[i] + next[i]*skip[i]) % size
+			}
+			table.lookup[slot] = int32(i)
+			next[i]++
+
+			filled++
+			if filled == size {
+				return table
+			}
+		}
+	}
+}
+
+// nextPrime returns the smallest prime >= n. Maglev needs a prime table size
+// so every skip value yields a full permutation.
+func nextPrime(n uint64) uint64 {
+	if n <= 2 {
+		return 2
+	}
+	if n%2 == 0 {
+		n++
+	}
+	for ; ; n += 2 {
+		prime := true
+		for d := uint64(3); d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
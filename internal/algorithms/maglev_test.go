@@ -0,0 +1,84 @@
+package algorithms
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func TestMaglevCandidateSubset(t *testing.T) {
+	balancer, err := newMaglev(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := balancer.(*maglev)
+
+	backends := weightedBackends(1, 1, 1, 1)
+	m.ObserveBackends(backends)
+	m.builds.Wait()
+	table := m.table.Load()
+
+	assigned := make(map[string]*config.Backend)
+	for i := 0; i < 100; i++ {
+		req := &Request{ClientIP: fmt.Sprintf("10.0.0.%d", i)}
+		assigned[req.ClientIP], _ = m.Pick(backends, req)
+	}
+
+	// leaving a backend out of a pick neither rebuilds the table nor moves
+	// the keys of the other backends
+	subset := backends[1:]
+	for ip, want := range assigned {
+		got, _ := m.Pick(subset, &Request{ClientIP: ip})
+		if got == backends[0] {
+			t.Fatalf("client %s sent to a backend that isn't a candidate", ip)
+		}
+		if want != backends[0] && got != want {
+			t.Fatalf("client %s moved from %s to %s", ip, want.URL, got.URL)
+		}
+	}
+	if m.table.Load() != table {
+		t.Error("a pick rebuilt the table")
+	}
+
+	// nor does reporting the same backends in another order
+	m.ObserveBackends([]*config.Backend{backends[3], backends[2], backends[1], backends[0]})
+	m.builds.Wait()
+	if m.table.Load() != table {
+		t.Error("reordered backends rebuilt the table")
+	}
+}
+
+func TestMaglevPicksBeforeTableIsBuilt(t *testing.T) {
+	balancer, err := newMaglev(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := balancer.(*maglev)
+
+	// picks don't wait for a table, nor build one themselves
+	backends := weightedBackends(1, 1, 1)
+	for i := 0; i < 10; i++ {
+		got, err := m.Pick(backends, &Request{ClientIP: fmt.Sprintf("10.0.0.%d", i)})
+		if err != nil || got == nil {
+			t.Fatalf("pick without a table: %v, %v", got, err)
+		}
+	}
+	if m.table.Load() != nil {
+		t.Fatal("a pick built the table")
+	}
+
+	// a build for backends that were reported since is thrown away
+	m.ObserveBackends(backends)
+	m.builds.Wait()
+	table := m.table.Load()
+
+	m.mu.Lock()
+	gen := m.gen
+	m.gen++
+	m.mu.Unlock()
+	m.build([]string{"http://backend-9"}, gen)
+	if m.table.Load() != table {
+		t.Error("a stale build replaced the table")
+	}
+}
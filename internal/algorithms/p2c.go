@@ -0,0 +1,83 @@
+package algorithms
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// p2c samples two distinct backends at random and picks the less loaded of
+// the two. It gets close to least connections while only ever looking at
+// two backends, which matters once the registry holds hundreds of them.
+type p2c struct {
+	measure string
+	latency *peakEWMA // nil when balancing on connections
+}
+
+func newP2C(cfg *config.Config) (Balancer, error) {
+	switch cfg.P2C.Load {
+	case "", config.P2CLoadConnections:
+		return &p2c{}, nil
+	case config.P2CLoadLatency:
+	default:
+		return nil, fmt.Errorf("unsupported p2c load: %s", cfg.P2C.Load)
+	}
+
+	measure, latency, err := latencySettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &p2c{measure: measure, latency: latency}, nil
+}
+
+func (p *p2c) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	switch len(backends) {
+	case 0:
+		return nil, ErrNoBackend
+	case 1:
+		return backends[0], nil
+	}
+
+	i := rand.IntN(len(backends))
+	j := rand.IntN(len(backends) - 1)
+	if j >= i {
+		j++
+	}
+
+	a, b := backends[i], backends[j]
+	if p.lessLoaded(b, a) {
+		return b, nil
+	}
+	return a, nil
+}
+
+func (p *p2c) lessLoaded(a, b *config.Backend) bool {
+	if p.latency != nil {
+		return p.latency.score(a) < p.latency.score(b)
+	}
+
+	aConns := float64(atomic.LoadInt64(&a.Metrics.ActiveConnections))
+	bConns := float64(atomic.LoadInt64(&b.Metrics.ActiveConnections))
+	return aConns*b.EffectiveWeight() < bConns*a.EffectiveWeight()
+}
+
+func (p *p2c) ObserveLatency(b *config.Backend, measure string, latency time.Duration) {
+	if p.latency != nil && measure == p.measure {
+		p.latency.observe(b, latency)
+	}
+}
+
+func (p *p2c) ObserveFailure(b *config.Backend) {
+	if p.latency != nil {
+		p.latency.observeFailure(b)
+	}
+}
+
+func (p *p2c) ObserveBackends(backends []*config.Backend) {
+	if p.latency != nil {
+		p.latency.prune(backends)
+	}
+}
@@ -0,0 +1,25 @@
+package algorithms
+
+import (
+	"sync/atomic"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// roundRobin hands out backends in turn using a shared counter.
+type roundRobin struct {
+	next uint64
+}
+
+func newRoundRobin(cfg *config.Config) (Balancer, error) {
+	return &roundRobin{}, nil
+}
+
+func (rr *roundRobin) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+
+	n := atomic.AddUint64(&rr.next, 1)
+	return backends[(n-1)%uint64(len(backends))], nil
+}
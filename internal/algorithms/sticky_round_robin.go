@@ -0,0 +1,157 @@
+package algorithms
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+	"github.com/shubhamojha1/heimdall/internal/metrics"
+)
+
+const (
+	defaultStickyTableSize   = 10000
+	defaultStickyIdleTimeout = 300 * time.Second
+)
+
+// stickyRoundRobin assigns each new client IP a backend in round robin order
+// and keeps sending that client to it. Assignments live in an LRU table that
+// holds at most maxEntries clients and forgets clients that have been idle
+// for longer than idleTimeout. Assignments to a backend are dropped once it
+// stops serving or leaves the pool; while it is only left out of a pick,
+// e.g. for being at MaxConns, its clients go elsewhere for that request but
+// keep their assignment.
+type stickyRoundRobin struct {
+	maxEntries  int
+	idleTimeout time.Duration
+
+	mu      sync.Mutex
+	rr      roundRobin
+	entries map[string]*list.Element   // client IP -> element in lru
+	lru     *list.List                 // most recently used at the front
+	members map[string]*config.Backend // the pool's backends by URL, see ObserveBackends
+
+	hits, lookups uint64
+}
+
+type stickyEntry struct {
+	clientIP string
+	backend  string // backend URL
+	lastSeen time.Time
+}
+
+func newStickyRoundRobin(cfg *config.Config) (Balancer, error) {
+	sticky := cfg.L4().Sticky
+	if sticky.TableSize < 0 || sticky.IdleTimeout < 0 {
+		return nil, fmt.Errorf("sticky table_size and idle_timeout must not be negative")
+	}
+
+	s := &stickyRoundRobin{
+		maxEntries:  sticky.TableSize,
+		idleTimeout: sticky.IdleTimeout,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		members:     make(map[string]*config.Backend),
+	}
+	if s.maxEntries == 0 {
+		s.maxEntries = defaultStickyTableSize
+	}
+	if s.idleTimeout == 0 {
+		s.idleTimeout = defaultStickyIdleTimeout
+	}
+	return s, nil
+}
+
+func (s *stickyRoundRobin) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lookups++
+
+	if elem, ok := s.entries[req.ClientIP]; ok {
+		entry := elem.Value.(*stickyEntry)
+		b := findByURL(backends, entry.backend)
+		switch {
+		case now.Sub(entry.lastSeen) > s.idleTimeout:
+			s.remove(elem)
+		case b != nil:
+			s.hits++
+			entry.lastSeen = now
+			s.lru.MoveToFront(elem)
+			return b, nil
+		case s.serving(entry.backend):
+			// only left out of this pick, e.g. for being at MaxConns;
+			// serve the client elsewhere this time
+			entry.lastSeen = now
+			s.lru.MoveToFront(elem)
+			return s.rr.Pick(backends, req)
+		default:
+			s.remove(elem)
+		}
+	}
+
+	b, err := s.rr.Pick(backends, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.entries[req.ClientIP] = s.lru.PushFront(&stickyEntry{
+		clientIP: req.ClientIP,
+		backend:  b.URL,
+		lastSeen: now,
+	})
+
+	// drop the least recently used clients, idle ones first by construction
+	for s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+	for elem := s.lru.Back(); elem != nil && now.Sub(elem.Value.(*stickyEntry).lastSeen) > s.idleTimeout; elem = s.lru.Back() {
+		s.remove(elem)
+	}
+	return b, nil
+}
+
+// ObserveBackends drops every assignment to a backend that left the pool or
+// stopped serving.
+func (s *stickyRoundRobin) ObserveBackends(backends []*config.Backend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.members)
+	for _, b := range backends {
+		s.members[b.URL] = b
+	}
+
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if !s.serving(elem.Value.(*stickyEntry).backend) {
+			s.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// serving reports whether the backend at url is in the pool and serving.
+// Callers hold s.mu.
+func (s *stickyRoundRobin) serving(url string) bool {
+	b, ok := s.members[url]
+	return ok && b.IsServing()
+}
+
+func (s *stickyRoundRobin) remove(elem *list.Element) {
+	delete(s.entries, elem.Value.(*stickyEntry).clientIP)
+	s.lru.Remove(elem)
+}
+
+func (s *stickyRoundRobin) ReportMetrics(m *metrics.LoadBalancerMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m.StickyTableSize = s.lru.Len()
+	if s.lookups > 0 {
+		m.StickyHitRate = float64(s.hits) / float64(s.lookups)
+	}
+}
@@ -0,0 +1,50 @@
+package algorithms
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func TestStickyRoundRobinKeepsAffinity(t *testing.T) {
+	balancer, err := newStickyRoundRobin(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := balancer.(*stickyRoundRobin)
+
+	backends := weightedBackends(1, 1, 1)
+	for _, b := range backends {
+		b.Enabled = true
+		b.Metrics.HealthCheckStatus = true
+	}
+	s.ObserveBackends(backends)
+
+	assigned := make(map[string]*config.Backend)
+	for i := 0; i < 30; i++ {
+		req := &Request{ClientIP: fmt.Sprintf("10.0.0.%d", i)}
+		assigned[req.ClientIP], _ = s.Pick(backends, req)
+	}
+
+	// a backend left out of a pick, e.g. at MaxConns, keeps its clients
+	for ip := range assigned {
+		s.Pick(backends[1:], &Request{ClientIP: ip})
+	}
+	for ip, want := range assigned {
+		if got, _ := s.Pick(backends, &Request{ClientIP: ip}); got != want {
+			t.Fatalf("client %s moved from %s to %s", ip, want.URL, got.URL)
+		}
+	}
+
+	// one that stops serving loses them
+	backends[0].Metrics.HealthCheckStatus = false
+	s.ObserveBackends(backends)
+	for ip, want := range assigned {
+		if want == backends[0] {
+			if _, ok := s.entries[ip]; ok {
+				t.Fatalf("client %s still assigned to a backend that stopped serving", ip)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package algorithms
+
+import (
+	"sync"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// weightedRoundRobin is nginx's smooth weighted round robin. On every pick
+// each backend's current weight grows by its configured weight, the backend
+// with the highest current weight wins and is pushed back by the total. A
+// backend with weight 5 next to two with weight 1 is picked a a b a c a a
+// rather than a a a a a b c.
+//
+// Current weights are kept per backend URL and weights are read on every
+// pick, so a weight change from the registry applies immediately without
+// throwing the accumulated state away.
+type weightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[string]float64 // backend URL -> current weight
+}
+
+func newWeightedRoundRobin(cfg *config.Config) (Balancer, error) {
+	return &weightedRoundRobin{
+		current: make(map[string]float64),
+	}, nil
+}
+
+func (w *weightedRoundRobin) Pick(backends []*config.Backend, req *Request) (*config.Backend, error) {
+	if len(backends) == 0 {
+		return nil, ErrNoBackend
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var best *config.Backend
+	total := 0.0
+	for _, b := range backends {
+		weight := b.EffectiveWeight()
+		total += weight
+		w.current[b.URL] += weight
+		if best == nil || w.current[b.URL] > w.current[best.URL] {
+			best = b
+		}
+	}
+	w.current[best.URL] -= total
+
+	// forget backends that left the snapshot
+	if len(w.current) > len(backends) {
+		for url := range w.current {
+			if findByURL(backends, url) == nil {
+				delete(w.current, url)
+			}
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,155 @@
+package algorithms
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func weightedBackends(weights ...int) []*config.Backend {
+	backends := make([]*config.Backend, len(weights))
+	for i, w := range weights {
+		backends[i] = &config.Backend{
+			Name:   string(rune('a' + i)),
+			URL:    fmt.Sprintf("http://backend-%d", i),
+			Weight: w,
+		}
+	}
+	return backends
+}
+
+func TestWeightedRoundRobinDistribution(t *testing.T) {
+	ratios := [][]int{
+		{1, 1},
+		{2, 1},
+		{3, 2, 1},
+		{5, 1, 1},
+		{10, 1},
+		{1, 4, 7, 2},
+	}
+
+	for _, weights := range ratios {
+		t.Run(fmt.Sprint(weights), func(t *testing.T) {
+			balancer, _ := newWeightedRoundRobin(&config.Config{})
+			backends := weightedBackends(weights...)
+
+			total := 0
+			for _, w := range weights {
+				total += w
+			}
+
+			// every full cycle of total picks hands each backend exactly its weight
+			for cycle := 0; cycle < 10; cycle++ {
+				counts := make(map[string]int)
+				for i := 0; i < total; i++ {
+					b, err := balancer.Pick(backends, &Request{})
+					if err != nil {
+						t.Fatalf("Pick: %v", err)
+					}
+					counts[b.URL]++
+				}
+
+				for _, b := range backends {
+					if counts[b.URL] != b.Weight {
+						t.Fatalf("cycle %d: %s picked %d times, want %d", cycle, b.URL, counts[b.URL], b.Weight)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedRoundRobinIsSmooth(t *testing.T) {
+	balancer, _ := newWeightedRoundRobin(&config.Config{})
+	backends := weightedBackends(5, 1, 1)
+
+	var order strings.Builder
+	for i := 0; i < 7; i++ {
+		b, _ := balancer.Pick(backends, &Request{})
+		order.WriteString(b.Name)
+	}
+
+	if got, want := order.String(), "aabacaa"; got != want {
+		t.Errorf("pick order = %s, want %s", got, want)
+	}
+}
+
+func TestWeightedRoundRobinWeightChange(t *testing.T) {
+	balancer, _ := newWeightedRoundRobin(&config.Config{})
+	backends := weightedBackends(1, 1)
+
+	for i := 0; i < 3; i++ {
+		balancer.Pick(backends, &Request{})
+	}
+
+	// the registry bumps the second backend, mid cycle
+	backends[1].Weight = 3
+
+	counts := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		b, _ := balancer.Pick(backends, &Request{})
+		counts[b.URL]++
+	}
+
+	if counts[backends[0].URL] != 100 || counts[backends[1].URL] != 300 {
+		t.Errorf("after weight change got %v, want 100/300", counts)
+	}
+}
+
+func TestWeightedRoundRobinMissingWeight(t *testing.T) {
+	balancer, _ := newWeightedRoundRobin(&config.Config{})
+	backends := weightedBackends(0, 0, 0)
+
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		b, _ := balancer.Pick(backends, &Request{})
+		counts[b.URL]++
+	}
+
+	for _, b := range backends {
+		if counts[b.URL] != 10 {
+			t.Errorf("%s picked %d times, want 10", b.URL, counts[b.URL])
+		}
+	}
+}
+
+func TestWeightedRoundRobinSlowStart(t *testing.T) {
+	balancer, _ := newWeightedRoundRobin(&config.Config{})
+	backends := weightedBackends(2, 2)
+
+	// halfway through its warm up the second backend has half its weight
+	backends[1].SlowStart = time.Hour
+	backends[1].WarmingSince = time.Now().Add(-30 * time.Minute)
+
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		b, _ := balancer.Pick(backends, &Request{})
+		counts[b.URL]++
+	}
+
+	if n := counts[backends[1].URL]; n < 95 || n > 105 {
+		t.Errorf("warming backend picked %d of 300 times, want about 100", n)
+	}
+
+	// once warm it gets its full share
+	backends[1].WarmingSince = time.Now().Add(-time.Hour)
+	counts = make(map[string]int)
+	for i := 0; i < 300; i++ {
+		b, _ := balancer.Pick(backends, &Request{})
+		counts[b.URL]++
+	}
+
+	if n := counts[backends[1].URL]; n < 149 || n > 151 {
+		t.Errorf("warm backend picked %d of 300 times, want about 150", n)
+	}
+}
+
+func TestWeightedRoundRobinNoBackends(t *testing.T) {
+	balancer, _ := newWeightedRoundRobin(&config.Config{})
+	if _, err := balancer.Pick(nil, &Request{}); err != ErrNoBackend {
+		t.Errorf("Pick with no backends returned %v, want ErrNoBackend", err)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/shubhamojha1/heimdall/internal/metrics"
@@ -24,6 +27,7 @@ const (
 	AlgorithmWeightedRoundRobin Algorithm = "weighted_round_robin"
 	AlgorithmLeastConnections   Algorithm = "least_connections"
 	AlgorithmStickyRoundRobin   Algorithm = "sticky_round_robin"
+	AlgorithmMaglev             Algorithm = "maglev"
 
 	// L7 algorithms
 	AlgorithmURLHash      Algorithm = "url_hash"
@@ -33,36 +37,80 @@ const (
 	// Both layers
 	AlgorithmIPHash    Algorithm = "ip_hash"
 	AlgorithmLeastTime Algorithm = "least_time"
+	AlgorithmP2C       Algorithm = "p2c" // power of two choices
 )
 
+var (
+	customAlgorithmsMu sync.RWMutex
+	customAlgorithms   = map[Algorithm][]Layer{}
+)
+
+// RegisterAlgorithm marks a custom algorithm as valid for the given layers.
+// It is called by algorithms.Register.
+func RegisterAlgorithm(a Algorithm, layers ...Layer) {
+	customAlgorithmsMu.Lock()
+	defer customAlgorithmsMu.Unlock()
+
+	customAlgorithms[a] = layers
+}
+
 func (a Algorithm) IsValidForLayer(l Layer) bool {
+	customAlgorithmsMu.RLock()
+	layers, custom := customAlgorithms[a]
+	customAlgorithmsMu.RUnlock()
+	if custom {
+		return slices.Contains(layers, l)
+	}
+
 	switch l {
 	case LayerFour:
 		switch a {
-		case AlgorithmRoundRobin, AlgorithmWeightedRoundRobin, AlgorithmStickyRoundRobin, AlgorithmLeastConnections, AlgorithmIPHash, AlgorithmLeastTime:
+		case AlgorithmRoundRobin, AlgorithmWeightedRoundRobin, AlgorithmStickyRoundRobin, AlgorithmLeastConnections, AlgorithmIPHash, AlgorithmLeastTime, AlgorithmP2C, AlgorithmMaglev:
 			return true
 		}
 
 	case LayerSeven:
 		switch a {
-		case AlgorithmURLHash, AlgorithmCookieBased, AlgorithmContentBased, AlgorithmIPHash, AlgorithmLeastTime:
+		case AlgorithmURLHash, AlgorithmCookieBased, AlgorithmContentBased, AlgorithmIPHash, AlgorithmLeastTime, AlgorithmP2C:
 			return true
 		}
 	}
 	return false
 }
 
+// backend labels used for locality aware routing
+const (
+	LabelZone   = "zone"
+	LabelRegion = "region"
+)
+
+// what the hash algorithms (ip_hash, url_hash) key on when sticky is enabled
+const (
+	HashMethodIP     = "ip"
+	HashMethodURL    = "url"
+	HashMethodHeader = "header" // the header named by hash_key
+	HashMethodQuery  = "query"  // the query parameter named by hash_key
+)
+
 type Backend struct {
 	Name   string `json:"name"`
 	URL    string `json:"url"`
 	Weight int    `json:"weight"`
+	Pool   string `json:"pool,omitempty"` // empty means DefaultPool
+	// Failover tier: 0 for primaries, 1 for backups and so on. A tier only
+	// gets traffic once the tiers before it are short of healthy capacity,
+	// see Config.Failover.
+	Priority int `json:"priority,omitempty"`
+	// Free form labels such as zone, region, rack or version. Locality
+	// aware routing reads LabelZone and LabelRegion.
+	Labels map[string]string `json:"labels,omitempty"`
 	// Port   int    `json:"port"`
 
 	// Connection limits
 	MaxConns          int           `json:"max_connections"`
 	MaxQueueSize      int           `json:"max_queue_size"`
-	QueueTimeout      time.Duration `json:"queue_timeout"`
-	ConnectionTimeout time.Duration `json:"connection_timeout"`
+	QueueTimeout      time.Duration `json:"queue_timeout"`      // registered in seconds
+	ConnectionTimeout time.Duration `json:"connection_timeout"` // registered in seconds
 
 	// Server capacity settings
 	MaxCPUUsage     float64       `json:"max_cpu_usage"`    // percentage threshold
@@ -73,6 +121,13 @@ type Backend struct {
 	QueueFull       bool `json:"queue_full"`
 	Enabled         bool `json:"enabled"`
 	MaintenanceMode bool `json:"maintenance_mode"`
+	// set by outlier detection on the load balancer, the backend is out of
+	// rotation until then
+	EjectedUntil time.Time `json:"-"`
+	// set by the load balancer: the backend's share of traffic ramps up
+	// over SlowStart from when it joined or last became healthy
+	SlowStart    time.Duration `json:"-"`
+	WarmingSince time.Time     `json:"-"`
 
 	SSL struct {
 		Enabled  bool   `json:"enabled"`
@@ -93,8 +148,13 @@ type Config struct {
 	Listen struct {
 		Port    int    `json:"port"`
 		Address string `json:"address"`
+		Pool    string `json:"pool,omitempty"` // pool serving this listener, defaults to DefaultPool
 	} `json:"listen"`
 
+	// Named backend pools. Backends register into a pool by name; pools
+	// not declared here use the top level settings.
+	Pools []Pool `json:"pools,omitempty"`
+
 	// remove backends[] as it will be managed and stored dynamically.
 	// Backends []Backend `json:"backends"`
 
@@ -104,6 +164,39 @@ type Config struct {
 
 	HealthCheck HealthCheck `json:"healthcheck"`
 
+	OutlierDetection OutlierDetection `json:"outlier_detection"`
+
+	CircuitBreaker CircuitBreaker `json:"circuit_breaker"`
+
+	// Backends that register or come back healthy start at a tenth of their
+	// weight and ramp up to all of it over this long. 0 turns it off.
+	SlowStart time.Duration `json:"slow_start"`
+
+	Failover struct {
+		// Share of a tier's weight that must be healthy for it to take all
+		// traffic alone, in percent. Below it the next tier joins in. With 0
+		// the next tier only joins once no backend in the tier is healthy.
+		MinHealthyPercent float64 `json:"min_healthy_percent"`
+	} `json:"failover"`
+
+	// Where this load balancer runs. Backends in the same zone are
+	// preferred, then backends in the same region, and other zones are only
+	// used when nothing closer is healthy and has capacity left.
+	Locality struct {
+		Zone   string `json:"zone,omitempty"`
+		Region string `json:"region,omitempty"`
+		// Relative share of traffic per zone, for shifting traffic between
+		// zones gradually. When set it replaces the local zone preference;
+		// zones left out only get traffic if no listed zone is healthy.
+		ZoneWeights map[string]int `json:"zone_weights,omitempty"`
+	} `json:"locality"`
+
+	Hash HashSettings `json:"hash"`
+
+	LeastTime LeastTimeSettings `json:"least_time"`
+
+	P2C P2CSettings `json:"p2c"`
+
 	Metrics struct {
 		Enabled bool `json:"enabled"`
 		Port    int  `json:"port"`
@@ -111,13 +204,160 @@ type Config struct {
 }
 
 type HealthCheck struct {
-	Enabled  bool          `json:"enabled"`
-	Protocol string        `json:"protocol"` // "tcp" for L4, "http" for L7
+	Enabled bool `json:"enabled"`
+	// "tcp", "http", "grpc" or "exec", defaults to tcp on l4 and http on l7
+	Protocol string        `json:"protocol"`
 	Interval time.Duration `json:"interval"`
 	Timeout  time.Duration `json:"timeout"`
 	Path     string        `json:"path"`
-	Port     int           `json:"port"`
-	Expected string        `json:"expected_status,omitempty"`
+	Port     int           `json:"port"` // probe this port instead of the backend's
+	// http: a status code such as "200", or text the response body must
+	// contain
+	Expected string `json:"expected_status,omitempty"`
+	// grpc: service to ask grpc.health.v1.Health about, empty for the whole
+	// server
+	Service string `json:"service,omitempty"`
+	// exec: command and arguments to run with the backend in BACKEND_URL,
+	// BACKEND_ADDR, BACKEND_HOST and BACKEND_PORT; exit status 0 passes
+	Command       []string `json:"command,omitempty"`
+	MaxConcurrent int      `json:"max_concurrent,omitempty"` // exec probes running at once per pool, defaults to 4
+
+	// consecutive probe results needed to flip a backend's state
+	HealthyThreshold   int `json:"healthy_threshold"`   // defaults to 2
+	UnhealthyThreshold int `json:"unhealthy_threshold"` // defaults to 3
+}
+
+// OutlierDetection ejects backends that keep failing real traffic: 5xx
+// responses, refused or reset connections and timeouts.
+type OutlierDetection struct {
+	Enabled bool `json:"enabled"`
+	// sliding window failures are counted over
+	Interval time.Duration `json:"interval"` // defaults to 10s
+	// a backend is ejected once FailurePercent of its requests in the
+	// window failed, provided it served at least MinRequests
+	FailurePercent float64 `json:"failure_percent"` // defaults to 50
+	MinRequests    int     `json:"min_requests"`    // defaults to 5
+	// the first ejection lasts BaseEjectionTime and each one after it
+	// twice as long as the one before, up to MaxEjectionTime
+	BaseEjectionTime time.Duration `json:"base_ejection_time"` // defaults to 30s
+	MaxEjectionTime  time.Duration `json:"max_ejection_time"`  // defaults to 300s
+	// share of a pool that may be ejected at once; a single backend
+	// always may be
+	MaxEjectionPercent float64 `json:"max_ejection_percent"` // defaults to 10
+}
+
+// CircuitBreaker stops sending traffic to a backend that keeps failing,
+// then lets a few probe requests through before trusting it again.
+type CircuitBreaker struct {
+	Enabled bool `json:"enabled"`
+	// the circuit opens after ConsecutiveFailures failures in a row
+	ConsecutiveFailures int `json:"consecutive_failures"` // defaults to 5
+	// or once ErrorPercent of the requests in Interval failed, provided
+	// there were at least MinRequests; 0 turns the error rate off
+	ErrorPercent float64       `json:"error_percent,omitempty"`
+	MinRequests  int           `json:"min_requests"` // defaults to 20
+	Interval     time.Duration `json:"interval"`     // defaults to 10s
+	// an open circuit turns half-open after OpenTimeout and lets
+	// HalfOpenRequests requests through; it closes once they all succeed
+	OpenTimeout      time.Duration `json:"open_timeout"`       // defaults to 30s
+	HalfOpenRequests int           `json:"half_open_requests"` // defaults to 1
+}
+
+// pool of backends that registered without one
+const DefaultPool = "default"
+
+// Pool overrides the top level balancing settings for one group of backends.
+type Pool struct {
+	Name        string          `json:"name"`
+	Algorithm   Algorithm       `json:"algorithm,omitempty"`
+	HealthCheck *HealthCheck    `json:"healthcheck,omitempty"`
+	Sticky      *StickySettings `json:"sticky,omitempty"` // layer 7 only
+
+	OutlierDetection *OutlierDetection `json:"outlier_detection,omitempty"`
+	CircuitBreaker   *CircuitBreaker   `json:"circuit_breaker,omitempty"`
+	SlowStart        time.Duration     `json:"slow_start,omitempty"`
+}
+
+// PoolName returns the pool the backend belongs to.
+func (b *Backend) PoolName() string {
+	if b.Pool == "" {
+		return DefaultPool
+	}
+	return b.Pool
+}
+
+// ListenPool returns the pool serving the listener.
+func (c *Config) ListenPool() string {
+	if c.Listen.Pool == "" {
+		return DefaultPool
+	}
+	return c.Listen.Pool
+}
+
+// ForPool returns the settings that apply to the named pool: the top level
+// config with the pool's overrides, if it declares any.
+func (c *Config) ForPool(name string) *Config {
+	pooled := *c
+	for _, p := range c.Pools {
+		if p.Name != name {
+			continue
+		}
+		if p.Algorithm != "" {
+			pooled.Algorithm = p.Algorithm
+		}
+		if p.HealthCheck != nil {
+			pooled.HealthCheck = *p.HealthCheck
+		}
+		if p.OutlierDetection != nil {
+			pooled.OutlierDetection = *p.OutlierDetection
+		}
+		if p.CircuitBreaker != nil {
+			pooled.CircuitBreaker = *p.CircuitBreaker
+		}
+		if p.SlowStart != 0 {
+			pooled.SlowStart = p.SlowStart
+		}
+		if l7, ok := c.LayerConfig.(L7Settings); ok && p.Sticky != nil {
+			l7.Sticky = *p.Sticky
+			pooled.LayerConfig = l7
+		}
+		break
+	}
+	return &pooled
+}
+
+// HashSettings tune the consistent hash algorithms (ip_hash, url_hash).
+type HashSettings struct {
+	// BoundedLoad caps each backend at LoadFactor times the mean number of
+	// active connections. Keys whose backend is at the cap spill over to the
+	// next backend on the ring.
+	BoundedLoad bool    `json:"bounded_load"`
+	LoadFactor  float64 `json:"load_factor"` // defaults to 1.25
+}
+
+// which latency least_time balances on
+const (
+	MeasureFirstByte = "first_byte" // until the response headers (L7) or first byte (L4) arrive
+	MeasureLastByte  = "last_byte"  // until the whole response has been streamed
+)
+
+// LeastTimeSettings tune the least_time algorithm.
+type LeastTimeSettings struct {
+	Measure string `json:"measure"` // one of the Measure constants, defaults to first_byte
+	// how long it takes for an observed latency to lose half its weight in
+	// the moving average; defaults to 10 seconds
+	HalfLife time.Duration `json:"half_life"`
+}
+
+// what p2c compares the two candidates on
+const (
+	P2CLoadConnections = "connections" // in-flight requests or connections
+	P2CLoadLatency     = "latency"     // peak EWMA latency, as configured under least_time
+)
+
+// P2CSettings tune the power of two choices algorithm.
+type P2CSettings struct {
+	Load string `json:"load"` // one of the P2CLoad constants, defaults to connections
 }
 
 type L4Settings struct {
@@ -128,6 +368,44 @@ type L4Settings struct {
 		// ConnectionTimeout	time.Duration	`json:connection_timeout,omitempty`
 		// IdleTimeout		time.Duration		`json:idle_timeout,omitempty`
 	} `json:"tcp"`
+
+	Maglev struct {
+		// lookup table entries, rounded up to a prime; defaults to 65537
+		TableSize int `json:"table_size"`
+	} `json:"maglev"`
+
+	// client affinity table of sticky_round_robin
+	Sticky struct {
+		TableSize   int           `json:"table_size"`   // defaults to 10000 clients
+		IdleTimeout time.Duration `json:"idle_timeout"` // defaults to 300 seconds
+	} `json:"sticky"`
+}
+
+// what RetrySettings.On may name besides the status codes 502, 503 and 504
+const (
+	RetryOnConnectFailure = "connect_failure"
+	RetryOnTimeout        = "timeout"
+)
+
+// RetrySettings let the layer 7 proxy try a failed request again on
+// another backend.
+type RetrySettings struct {
+	// retries after the first attempt, 0 turns retries off
+	Attempts int `json:"attempts"`
+	// failures worth a retry: RetryOnConnectFailure, RetryOnTimeout and
+	// "502", "503" or "504". Defaults to all of them.
+	On []string `json:"on,omitempty"`
+	// gives up on an attempt without response headers after this long;
+	// 0 leaves it to the backend
+	PerTryTimeout time.Duration `json:"per_try_timeout,omitempty"`
+	// also retry POST, PATCH and other methods that aren't idempotent
+	NonIdempotent bool `json:"non_idempotent,omitempty"`
+	// request bodies up to this size are buffered so they can be sent
+	// again; requests with larger bodies aren't retried
+	MaxBodyBytes int64 `json:"max_body_bytes"` // defaults to 64 KiB
+	// retries may add at most this share of requests, in percent, so a
+	// struggling fleet isn't hit by a retry storm
+	BudgetPercent float64 `json:"budget_percent"` // defaults to 20
 }
 
 type L7Settings struct {
@@ -142,12 +420,15 @@ type L7Settings struct {
 		ResponseHeaders map[string]string `json:"response_headers"`
 	} `json:"http"`
 
-	Sticky struct {
-		Enabled    bool   `json:"enabled"`
-		CookieName string `json:"cookie_name,omitempty"`
-		HashMethod string `json:"hash_method,omitempty"`
-		HashKey    string `json:"hash_key,omitempty"`
-	} `json:"sticky"`
+	Sticky StickySettings `json:"sticky"`
+
+	// Rules picking the pool for each request, evaluated from the highest
+	// priority down. Requests no rule matches go to the listener's pool.
+	Routing struct {
+		Rules []ContentRule `json:"rules"`
+	} `json:"routing"`
+
+	Retry RetrySettings `json:"retry"`
 
 	// Monitoring and metrics configuration
 	Monitoring struct {
@@ -190,6 +471,37 @@ type L7Settings struct {
 	} `json:"dynamic_config"`
 }
 
+type StickySettings struct {
+	Enabled    bool   `json:"enabled"`
+	CookieName string `json:"cookie_name,omitempty"`
+	HashMethod string `json:"hash_method,omitempty"` // one of the HashMethod constants
+	HashKey    string `json:"hash_key,omitempty"`    // header or query parameter name
+
+	// affinity cookie issued by cookie_based
+	CookieTTL      time.Duration `json:"cookie_ttl,omitempty"` // 0 issues a session cookie
+	CookieSecure   bool          `json:"cookie_secure,omitempty"`
+	CookieSameSite string        `json:"cookie_same_site,omitempty"` // "lax", "strict" or "none"
+	CookieDomain   string        `json:"cookie_domain,omitempty"`
+	CookiePath     string        `json:"cookie_path,omitempty"` // defaults to "/"
+	// HMAC key for signing the cookie. Load balancers sharing clients need
+	// the same secret; when empty a random one is generated at startup.
+	CookieSecret string `json:"cookie_secret,omitempty"`
+}
+
+// ContentRule sends requests that match every condition it sets to the named
+// pool. Header and query conditions with an empty value only require the
+// header or parameter to be present.
+type ContentRule struct {
+	Priority   int               `json:"priority"`       // higher runs first, ties keep file order
+	Host       string            `json:"host,omitempty"` // exact, or "*.example.com"
+	PathPrefix string            `json:"path_prefix,omitempty"`
+	PathRegex  string            `json:"path_regex,omitempty"`
+	Methods    []string          `json:"methods,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Query      map[string]string `json:"query,omitempty"`
+	Pool       string            `json:"pool"`
+}
+
 func LoadConfig(path string) (*Config, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -249,6 +561,8 @@ func LoadConfig(path string) (*Config, error) {
 			return nil, err
 		}
 		config = l4Config.Config
+		l4Config.L4Settings.TCP.KeepAliveTime *= time.Second
+		l4Config.L4Settings.Sticky.IdleTimeout *= time.Second
 		config.LayerConfig = l4Config.L4Settings
 
 	case LayerSeven:
@@ -260,6 +574,20 @@ func LoadConfig(path string) (*Config, error) {
 			return nil, err
 		}
 		config = l7Config.Config
+		l7 := &l7Config.L7Settings
+		l7.HTTP.IdleTimeout *= time.Second
+		l7.HTTP.WriteTimeout *= time.Second
+		l7.Sticky.CookieTTL *= time.Second
+		l7.Retry.PerTryTimeout *= time.Second
+		for _, p := range l7Config.Pools {
+			if p.Sticky != nil {
+				p.Sticky.CookieTTL *= time.Second
+			}
+		}
+		l7.Monitoring.UpdateInterval *= time.Second
+		l7.Monitoring.MetricsRetention *= time.Second
+		l7.Monitoring.Thresholds.MaxResponseTime *= time.Second
+		l7.DynamicConfig.UpdateInterval *= time.Second
 		config.LayerConfig = l7Config.L7Settings
 	default:
 		return nil, fmt.Errorf("unsupported layer type: %s", temp.Layer)
@@ -269,17 +597,136 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("algorithm %s is not valid for layer %s", config.Algorithm, config.Layer)
 	}
 
+	names := make(map[string]bool, len(config.Pools))
+	for _, p := range config.Pools {
+		if p.Name == "" {
+			return nil, fmt.Errorf("pool without a name")
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("pool %s declared twice", p.Name)
+		}
+		names[p.Name] = true
+
+		if p.Algorithm != "" && !p.Algorithm.IsValidForLayer(config.Layer) {
+			return nil, fmt.Errorf("algorithm %s of pool %s is not valid for layer %s", p.Algorithm, p.Name, config.Layer)
+		}
+	}
+	for i, rule := range config.L7().Routing.Rules {
+		if rule.Pool == "" {
+			return nil, fmt.Errorf("routing rule %d has no pool", i)
+		}
+	}
+
+	// durations in config.json are written in seconds
+	config.HealthCheck.Interval *= time.Second
+	config.HealthCheck.Timeout *= time.Second
+	config.OutlierDetection.Interval *= time.Second
+	config.OutlierDetection.BaseEjectionTime *= time.Second
+	config.OutlierDetection.MaxEjectionTime *= time.Second
+	config.CircuitBreaker.Interval *= time.Second
+	config.CircuitBreaker.OpenTimeout *= time.Second
+	config.SlowStart *= time.Second
+	for i := range config.Pools {
+		p := &config.Pools[i]
+		p.SlowStart *= time.Second
+		if p.HealthCheck != nil {
+			p.HealthCheck.Interval *= time.Second
+			p.HealthCheck.Timeout *= time.Second
+		}
+		if p.OutlierDetection != nil {
+			p.OutlierDetection.Interval *= time.Second
+			p.OutlierDetection.BaseEjectionTime *= time.Second
+			p.OutlierDetection.MaxEjectionTime *= time.Second
+		}
+		if p.CircuitBreaker != nil {
+			p.CircuitBreaker.Interval *= time.Second
+			p.CircuitBreaker.OpenTimeout *= time.Second
+		}
+	}
+	config.LeastTime.HalfLife *= time.Second
+
 	return &config, nil
 }
 
+// L4 returns the layer 4 settings, or the zero value for a layer 7 config.
+func (c *Config) L4() L4Settings {
+	settings, _ := c.LayerConfig.(L4Settings)
+	return settings
+}
+
+// L7 returns the layer 7 settings, or the zero value for a layer 4 config.
+func (c *Config) L7() L7Settings {
+	settings, _ := c.LayerConfig.(L7Settings)
+	return settings
+}
+
+// IsHealthy reports whether the backend can take traffic. A zero limit
+// (MaxConns, MaxCPUUsage, ...) means the limit is not enforced.
 func (b *Backend) IsHealthy() bool {
+	return b.IsServing() && !b.AtMaxConns()
+}
+
+// AtMaxConns reports whether every one of the backend's MaxConns
+// connections is in use.
+func (b *Backend) AtMaxConns() bool {
+	return b.MaxConns > 0 && atomic.LoadInt64(&b.Metrics.ActiveConnections) >= int64(b.MaxConns)
+}
+
+// IsServing is IsHealthy without the MaxConns limit: a serving backend that
+// is at MaxConns can still queue requests.
+func (b *Backend) IsServing() bool {
 	return b.Enabled &&
 		!b.MaintenanceMode &&
+		!time.Now().Before(b.EjectedUntil) &&
 		b.Metrics.HealthCheckStatus &&
-		b.Metrics.ActiveConnections < int64(b.MaxConns) &&
-		b.Metrics.CPUUsage < b.MaxCPUUsage &&
-		b.Metrics.MemoryUsage < b.MaxMemoryUsage &&
-		b.Metrics.ResponseTime < b.MaxResponseTime
+		(b.MaxCPUUsage == 0 || b.Metrics.CPUUsage < b.MaxCPUUsage) &&
+		(b.MaxMemoryUsage == 0 || b.Metrics.MemoryUsage < b.MaxMemoryUsage) &&
+		(b.MaxResponseTime == 0 || time.Duration(atomic.LoadInt64((*int64)(&b.Metrics.ResponseTime))) < b.MaxResponseTime)
+}
+
+// share of its weight a backend starts slow start with
+const slowStartMinFraction = 0.1
+
+// EffectiveWeight is the weight balancers should use; unset or invalid
+// weights count as 1. While the backend warms up, see SlowStart, it is a
+// fraction of that growing linearly from slowStartMinFraction.
+func (b *Backend) EffectiveWeight() float64 {
+	weight := float64(b.Weight)
+	if b.Weight <= 0 {
+		weight = 1
+	}
+
+	if b.SlowStart > 0 {
+		// a backend coming back from ejection warms up again too
+		start := b.WarmingSince
+		if b.EjectedUntil.After(start) {
+			start = b.EjectedUntil
+		}
+		if elapsed := time.Since(start); elapsed < b.SlowStart {
+			weight *= max(slowStartMinFraction, float64(elapsed)/float64(b.SlowStart))
+		}
+	}
+	return weight
+}
+
+// ApplySettings copies the registry-managed settings of src onto b, leaving
+// the metrics b has accumulated untouched.
+func (b *Backend) ApplySettings(src *Backend) {
+	b.Name = src.Name
+	b.Weight = src.Weight
+	b.Pool = src.Pool
+	b.Priority = src.Priority
+	b.Labels = src.Labels
+	b.MaxConns = src.MaxConns
+	b.MaxQueueSize = src.MaxQueueSize
+	b.QueueTimeout = src.QueueTimeout
+	b.ConnectionTimeout = src.ConnectionTimeout
+	b.MaxCPUUsage = src.MaxCPUUsage
+	b.MaxMemoryUsage = src.MaxMemoryUsage
+	b.MaxResponseTime = src.MaxResponseTime
+	b.Enabled = src.Enabled
+	b.MaintenanceMode = src.MaintenanceMode
+	b.SSL = src.SSL
 }
 
 func (b *Backend) UpdateMetrics(metrics metrics.ServerMetrics) {
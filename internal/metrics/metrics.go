@@ -29,9 +29,14 @@ type ServerMetrics struct {
 	HealthCheckStatus bool      `json:"health_check_status"`
 	FailureCount      int       `json:"failure_count"`
 	SuccessCount      int       `json:"success_count"`
+	// current streak of failed or successful health checks
+	ConsecutiveFailures  int    `json:"consecutive_failures"`
+	ConsecutiveSuccesses int    `json:"consecutive_successes"`
+	HealthCheckOutput    string `json:"health_check_output,omitempty"` // what the last exec probe printed
 
 	// Layer 7 specific metrics
 	RequestCount       int64 `json:"request_count,omitempty"`
+	RetryCount         int64 `json:"retry_count,omitempty"` // requests that were retries of one that failed elsewhere
 	SuccessfulRequests int64 `json:"successful_requests,omitempty"`
 	FailedRequests     int64 `json:"failed_requests,omitempty"`
 	Status5xx          int64 `json:"status_5xx,omitempty"`
@@ -49,7 +54,15 @@ type LoadBalancerMetrics struct {
 	ErrorRate           float64       `json:"error_rate"`
 	BackendsAvailable   int           `json:"backends_available"`
 	BackendsTotal       int           `json:"backends_total"`
+	BackendsEjected     int           `json:"backends_ejected"` // by outlier detection
+	CircuitsOpen        int           `json:"circuits_open"`    // open or half-open circuit breakers
+	Retries             int64         `json:"retries"`
+	RetriesDenied       int64         `json:"retries_denied"` // by the retry budget
 	LastUpdated         time.Time     `json:"last_updated"`
+
+	// Client affinity table (sticky_round_robin)
+	StickyTableSize int     `json:"sticky_table_size,omitempty"`
+	StickyHitRate   float64 `json:"sticky_hit_rate,omitempty"` // share of lookups that found a live entry
 }
 
 // MetricsStore interface for different metrics storage implementations
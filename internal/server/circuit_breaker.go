@@ -0,0 +1,258 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+const (
+	defaultBreakerConsecutiveFailures = 5
+	defaultBreakerMinRequests         = 20
+	defaultBreakerInterval            = 10 * time.Second
+	defaultBreakerOpenTimeout         = 30 * time.Second
+	defaultBreakerHalfOpenRequests    = 1
+)
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreakers holds the circuit breaker of every backend in a pool.
+type circuitBreakers struct {
+	settings config.CircuitBreaker
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker // by backend URL
+}
+
+type circuitBreaker struct {
+	state    circuitState
+	window   slidingWindow
+	failures int       // consecutive failures while closed
+	openedAt time.Time // when the circuit last opened
+
+	// half-open probes in flight and probes that succeeded
+	probes, passed int
+}
+
+// breakerToken is what acquire hands a request, to be given back to record
+// or release. Only the result of a request that took a probe slot of the
+// current half-open circuit counts as a probe.
+type breakerToken struct {
+	probe    bool
+	openedAt time.Time // of the circuit the probe slot belongs to
+}
+
+// isProbe reports whether tok holds one of br's current probe slots.
+func (br *circuitBreaker) isProbe(tok breakerToken) bool {
+	return br.state == circuitHalfOpen && tok.probe && tok.openedAt.Equal(br.openedAt)
+}
+
+// newCircuitBreakers returns nil when circuit breaking is disabled. The
+// methods of a nil *circuitBreakers let everything through.
+func newCircuitBreakers(settings config.CircuitBreaker) *circuitBreakers {
+	if !settings.Enabled {
+		return nil
+	}
+
+	if settings.ConsecutiveFailures <= 0 {
+		settings.ConsecutiveFailures = defaultBreakerConsecutiveFailures
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = defaultBreakerMinRequests
+	}
+	if settings.Interval <= 0 {
+		settings.Interval = defaultBreakerInterval
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = defaultBreakerHalfOpenRequests
+	}
+
+	return &circuitBreakers{
+		settings: settings,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// breaker returns b's circuit breaker, moving an open one to half-open once
+// its timeout has passed. Callers hold cb.mu.
+func (cb *circuitBreakers) breaker(b *config.Backend, now time.Time) *circuitBreaker {
+	br, ok := cb.breakers[b.URL]
+	if !ok {
+		br = &circuitBreaker{}
+		cb.breakers[b.URL] = br
+	}
+
+	if br.state == circuitOpen && now.Sub(br.openedAt) >= cb.settings.OpenTimeout {
+		br.state = circuitHalfOpen
+		br.probes, br.passed = 0, 0
+		log.Printf("[Breaker] event=circuit_half_open backend=%s", b.URL)
+	}
+	return br
+}
+
+// ready reports whether b's circuit would let a request through, without
+// taking a half-open probe slot.
+func (cb *circuitBreakers) ready(b *config.Backend) bool {
+	if cb == nil {
+		return true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	br := cb.breaker(b, time.Now())
+	switch br.state {
+	case circuitOpen:
+		return false
+	case circuitHalfOpen:
+		return br.probes < cb.settings.HalfOpenRequests
+	}
+	return true
+}
+
+// acquire lets one request through b's circuit, taking a probe slot when
+// it's half-open. Every acquired request must end in record or release with
+// the token it got.
+func (cb *circuitBreakers) acquire(b *config.Backend) (breakerToken, bool) {
+	if cb == nil {
+		return breakerToken{}, true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	br := cb.breaker(b, time.Now())
+	switch br.state {
+	case circuitOpen:
+		return breakerToken{}, false
+	case circuitHalfOpen:
+		if br.probes >= cb.settings.HalfOpenRequests {
+			return breakerToken{}, false
+		}
+		br.probes++
+		return breakerToken{probe: true, openedAt: br.openedAt}, true
+	}
+	return breakerToken{}, true
+}
+
+// release hands back a request that ended without saying anything about
+// the backend, e.g. because the client went away.
+func (cb *circuitBreakers) release(b *config.Backend, tok breakerToken) {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if br, ok := cb.breakers[b.URL]; ok && br.isProbe(tok) && br.probes > 0 {
+		br.probes--
+	}
+}
+
+// record feeds the outcome of an acquired request into b's circuit.
+func (cb *circuitBreakers) record(b *config.Backend, tok breakerToken, failed bool) {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := time.Now()
+	br := cb.breaker(b, now)
+	switch br.state {
+	case circuitClosed:
+		br.window.add(cb.settings.Interval, failed, now)
+		if !failed {
+			br.failures = 0
+			return
+		}
+		br.failures++
+
+		requests, failures := br.window.counts(cb.settings.Interval, now)
+		tripped := br.failures >= cb.settings.ConsecutiveFailures ||
+			(cb.settings.ErrorPercent > 0 && requests >= cb.settings.MinRequests &&
+				float64(failures)*100 >= cb.settings.ErrorPercent*float64(requests))
+		if tripped {
+			cb.open(b, br, now)
+		}
+
+	case circuitHalfOpen:
+		if !br.isProbe(tok) {
+			return
+		}
+		if br.probes > 0 {
+			br.probes--
+		}
+		if failed {
+			cb.open(b, br, now)
+			return
+		}
+		br.passed++
+		if br.passed >= cb.settings.HalfOpenRequests {
+			*br = circuitBreaker{}
+			log.Printf("[Breaker] event=circuit_closed backend=%s", b.URL)
+		}
+	}
+	// requests that were already in flight when the circuit opened, or
+	// when it went half-open, don't count
+}
+
+// open trips br. Callers hold cb.mu.
+func (cb *circuitBreakers) open(b *config.Backend, br *circuitBreaker, now time.Time) {
+	br.state = circuitOpen
+	br.openedAt = now
+	br.failures = 0
+	br.window.reset()
+	log.Printf("[Breaker] event=circuit_open backend=%s timeout=%v", b.URL, cb.settings.OpenTimeout)
+}
+
+// tripped counts the circuits that are open or half-open.
+func (cb *circuitBreakers) tripped() int {
+	if cb == nil {
+		return 0
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	n := 0
+	for _, br := range cb.breakers {
+		if br.state != circuitClosed {
+			n++
+		}
+	}
+	return n
+}
+
+// prune drops the circuits of backends that left the pool.
+func (cb *circuitBreakers) prune(backends []*config.Backend) {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	current := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		current[b.URL] = true
+	}
+	for url := range cb.breakers {
+		if !current[url] {
+			delete(cb.breakers, url)
+		}
+	}
+}
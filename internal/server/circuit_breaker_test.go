@@ -0,0 +1,132 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func newTestBreakers(settings config.CircuitBreaker) (*circuitBreakers, *config.Backend) {
+	settings.Enabled = true
+	settings.OpenTimeout = time.Minute
+	return newCircuitBreakers(settings), &config.Backend{URL: "http://a"}
+}
+
+// expire moves b's open circuit past its timeout.
+func expire(cb *circuitBreakers, b *config.Backend) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.breakers[b.URL].openedAt = time.Now().Add(-cb.settings.OpenTimeout)
+}
+
+func stateOf(cb *circuitBreakers, b *config.Backend) circuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.breaker(b, time.Now()).state
+}
+
+// request acquires a request through b's circuit and records its outcome.
+func request(t *testing.T, cb *circuitBreakers, b *config.Backend, failed bool) {
+	t.Helper()
+
+	tok, ok := cb.acquire(b)
+	if !ok {
+		t.Fatal("circuit turned the request away")
+	}
+	cb.record(b, tok, failed)
+}
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	cb, b := newTestBreakers(config.CircuitBreaker{ConsecutiveFailures: 3})
+
+	request(t, cb, b, true)
+	request(t, cb, b, true)
+	request(t, cb, b, false) // resets the streak
+	request(t, cb, b, true)
+	request(t, cb, b, true)
+	if got := stateOf(cb, b); got != circuitClosed {
+		t.Fatalf("state %v after two failures in a row, want closed", got)
+	}
+
+	request(t, cb, b, true)
+	if got := stateOf(cb, b); got != circuitOpen {
+		t.Fatalf("state %v after three failures in a row, want open", got)
+	}
+	if _, ok := cb.acquire(b); ok {
+		t.Fatal("open circuit let a request through")
+	}
+
+	expire(cb, b)
+	probe, ok := cb.acquire(b)
+	if !ok || stateOf(cb, b) != circuitHalfOpen {
+		t.Fatal("circuit past its timeout should let a probe through")
+	}
+	if _, ok := cb.acquire(b); ok {
+		t.Fatal("half-open circuit let more than HalfOpenRequests through")
+	}
+
+	// a failed probe opens the circuit again
+	cb.record(b, probe, true)
+	if got := stateOf(cb, b); got != circuitOpen {
+		t.Fatalf("state %v after a failed probe, want open", got)
+	}
+
+	expire(cb, b)
+	request(t, cb, b, false)
+	if got := stateOf(cb, b); got != circuitClosed {
+		t.Fatalf("state %v after a successful probe, want closed", got)
+	}
+}
+
+func TestCircuitBreakerIgnoresRequestsFromBeforeHalfOpen(t *testing.T) {
+	cb, b := newTestBreakers(config.CircuitBreaker{ConsecutiveFailures: 1})
+
+	stale, _ := cb.acquire(b)
+	request(t, cb, b, true)
+	expire(cb, b)
+
+	// a request acquired while the circuit was closed is no probe
+	cb.record(b, stale, false)
+	if got := stateOf(cb, b); got != circuitHalfOpen {
+		t.Fatalf("state %v after a result from before the circuit opened, want half-open", got)
+	}
+	cb.release(b, stale)
+
+	probe, ok := cb.acquire(b)
+	if !ok {
+		t.Fatal("probe slot was taken by a result from before the circuit opened")
+	}
+
+	// neither is a probe from an earlier half-open circuit
+	cb.record(b, probe, true)
+	expire(cb, b)
+	if _, ok := cb.acquire(b); !ok {
+		t.Fatal("half-open circuit turned away its probe")
+	}
+	cb.record(b, probe, false)
+	if got := stateOf(cb, b); got != circuitHalfOpen {
+		t.Fatalf("state %v after a probe of an earlier half-open circuit, want half-open", got)
+	}
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	cb, b := newTestBreakers(config.CircuitBreaker{
+		ConsecutiveFailures: 100,
+		ErrorPercent:        50,
+		MinRequests:         10,
+	})
+
+	for i := 0; i < 9; i++ {
+		request(t, cb, b, i%2 == 0)
+	}
+	if got := stateOf(cb, b); got != circuitClosed {
+		t.Fatalf("state %v below MinRequests, want closed", got)
+	}
+
+	request(t, cb, b, false)
+	request(t, cb, b, true)
+	if got := stateOf(cb, b); got != circuitOpen {
+		t.Fatalf("state %v with half the requests failed, want open", got)
+	}
+}
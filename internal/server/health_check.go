@@ -0,0 +1,356 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+	defaultHealthyThreshold    = 2
+	defaultUnhealthyThreshold  = 3
+
+	// how much of the interval a probe may be moved by, in either direction
+	healthCheckJitter = 0.1
+
+	// how much of a response body an http probe reads to match Expected
+	maxHealthCheckBody = 64 << 10
+
+	defaultMaxConcurrentExec = 4
+	// how much of an exec probe's output is kept in the backend's metrics
+	maxHealthCheckOutput = 4 << 10
+)
+
+// runHealthChecks keeps one prober running for every known backend whose
+// pool has health checks enabled, until the load balancer is stopped.
+func (lb *LoadBalancer) runHealthChecks() {
+	probers := make(map[string]context.CancelFunc) // backend URL -> stop
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		lb.mu.RLock()
+		current := make(map[string]*config.Backend, len(lb.backends))
+		for _, b := range lb.backends {
+			if lb.healthCheckFor(b).Enabled {
+				current[b.URL] = b
+			}
+		}
+		lb.mu.RUnlock()
+
+		for url, stop := range probers {
+			if _, ok := current[url]; !ok {
+				stop()
+				delete(probers, url)
+			}
+		}
+		for url, b := range current {
+			if _, ok := probers[url]; !ok {
+				ctx, stop := context.WithCancel(context.Background())
+				probers[url] = stop
+				go lb.probeLoop(ctx, b)
+			}
+		}
+
+		select {
+		case <-lb.StopChan:
+			for _, stop := range probers {
+				stop()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// healthCheckFor returns the health check settings of b's pool. Without a
+// protocol, l4 backends are probed over tcp and l7 backends over http.
+// Callers hold lb.mu.
+func (lb *LoadBalancer) healthCheckFor(b *config.Backend) config.HealthCheck {
+	cfg := lb.Configuration
+	if p, ok := lb.pools[b.PoolName()]; ok {
+		cfg = p.config
+	}
+
+	hc := cfg.HealthCheck
+	if hc.Protocol == "" {
+		hc.Protocol = "http"
+		if cfg.Layer == config.LayerFour {
+			hc.Protocol = "tcp"
+		}
+	}
+	return hc
+}
+
+// probeLoop probes b on its own jittered schedule, so the probes for many
+// backends don't all fire at once.
+func (lb *LoadBalancer) probeLoop(ctx context.Context, b *config.Backend) {
+	lb.mu.RLock()
+	hc := lb.healthCheckFor(b)
+	var slots chan struct{}
+	if p, ok := lb.pools[b.PoolName()]; ok && hc.Protocol == "exec" {
+		slots = p.execSlots
+	}
+	lb.mu.RUnlock()
+
+	interval := hc.Interval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	// spread the first probes over a whole interval
+	timer := time.NewTimer(time.Duration(rand.Int64N(int64(interval))))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		// exec probes wait for a slot before their timeout starts, so a
+		// busy pool doesn't fail its own backends
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		output, err := probe(ctx, b, hc)
+		if slots != nil {
+			<-slots
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		lb.recordHealthCheck(b, hc, output, err)
+
+		jitter := (rand.Float64()*2 - 1) * healthCheckJitter
+		timer.Reset(interval + time.Duration(jitter*float64(interval)))
+	}
+}
+
+// recordHealthCheck stores the outcome of a probe in b's metrics. The
+// backend only changes state once the healthy or unhealthy threshold of
+// consecutive results is reached, so a single odd probe doesn't flap it.
+func (lb *LoadBalancer) recordHealthCheck(b *config.Backend, hc config.HealthCheck, output string, err error) {
+	rise := hc.HealthyThreshold
+	if rise <= 0 {
+		rise = defaultHealthyThreshold
+	}
+	fall := hc.UnhealthyThreshold
+	if fall <= 0 {
+		fall = defaultUnhealthyThreshold
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	m := &b.Metrics
+	m.LastHealthCheck = time.Now()
+	m.HealthCheckOutput = output
+
+	if err == nil {
+		m.SuccessCount++
+		m.ConsecutiveSuccesses++
+		m.ConsecutiveFailures = 0
+		if !m.HealthCheckStatus && m.ConsecutiveSuccesses >= rise {
+			m.HealthCheckStatus = true
+			b.WarmingSince = m.LastHealthCheck
+			log.Printf("[Health] event=backend_up backend=%s consecutive_successes=%d", b.URL, m.ConsecutiveSuccesses)
+		}
+		return
+	}
+
+	m.FailureCount++
+	m.ConsecutiveFailures++
+	m.ConsecutiveSuccesses = 0
+	if m.HealthCheckStatus && m.ConsecutiveFailures >= fall {
+		m.HealthCheckStatus = false
+		log.Printf("[Health] event=backend_down backend=%s consecutive_failures=%d error=%q", b.URL, m.ConsecutiveFailures, err)
+	}
+}
+
+// probe runs one health check against b and returns why it failed, if it
+// did, along with anything an exec probe printed.
+func probe(ctx context.Context, b *config.Backend, hc config.HealthCheck) (string, error) {
+	timeout := hc.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr, err := backendAddress(b)
+	if err != nil {
+		return "", err
+	}
+	if hc.Port != 0 {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return "", err
+		}
+		addr = net.JoinHostPort(host, strconv.Itoa(hc.Port))
+	}
+
+	switch hc.Protocol {
+	case "tcp":
+		return "", probeTCP(ctx, addr)
+	case "http":
+		return "", probeHTTP(ctx, b, addr, hc)
+	case "grpc":
+		return "", probeGRPC(ctx, b, addr, hc)
+	case "exec":
+		return probeExec(ctx, b, addr, hc)
+	}
+	return "", fmt.Errorf("unsupported health check protocol: %s", hc.Protocol)
+}
+
+func probeTCP(ctx context.Context, addr string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeHTTP sends a GET for hc.Path. Expected may name the status code to
+// expect, e.g. "204"; any other value must appear in the body of a 2xx
+// response. Without Expected any 2xx status passes.
+func probeHTTP(ctx context.Context, b *config.Backend, addr string, hc config.HealthCheck) error {
+	scheme := "http"
+	if u, err := url.Parse(b.URL); err == nil && u.Scheme == "https" {
+		scheme = "https"
+	}
+	target := url.URL{Scheme: scheme, Host: addr, Path: hc.Path}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if status, err := strconv.Atoi(hc.Expected); err == nil {
+		if resp.StatusCode != status {
+			return fmt.Errorf("status %d, expected %d", resp.StatusCode, status)
+		}
+		return nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if hc.Expected == "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBody))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(body), hc.Expected) {
+		return fmt.Errorf("response body does not contain %q", hc.Expected)
+	}
+	return nil
+}
+
+// probeGRPC calls grpc.health.v1.Health/Check for hc.Service and passes only
+// when the backend reports SERVING.
+func probeGRPC(ctx context.Context, b *config.Backend, addr string, hc config.HealthCheck) error {
+	creds := insecure.NewCredentials()
+	if u, err := url.Parse(b.URL); err == nil && u.Scheme == "https" {
+		creds = credentials.NewTLS(&tls.Config{})
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: hc.Service})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc status %s", resp.GetStatus())
+	}
+	return nil
+}
+
+// probeExec runs hc.Command and passes when it exits with status 0. The
+// command is killed once ctx expires.
+func probeExec(ctx context.Context, b *config.Backend, addr string, hc config.HealthCheck) (string, error) {
+	if len(hc.Command) == 0 {
+		return "", fmt.Errorf("exec health check without a command")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+
+	var output truncatedBuffer
+	cmd := exec.CommandContext(ctx, hc.Command[0], hc.Command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"BACKEND_URL="+b.URL,
+		"BACKEND_ADDR="+addr,
+		"BACKEND_HOST="+host,
+		"BACKEND_PORT="+port,
+	)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// don't wait on children that outlive a killed command
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out: %w", err)
+	}
+	return output.String(), err
+}
+
+// truncatedBuffer keeps the first maxHealthCheckOutput bytes written to it
+// and quietly drops the rest, so a chatty command isn't cut off by a write
+// error.
+type truncatedBuffer struct {
+	buf []byte
+}
+
+func (t *truncatedBuffer) Write(p []byte) (int, error) {
+	if room := maxHealthCheckOutput - len(t.buf); room > 0 {
+		t.buf = append(t.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (t *truncatedBuffer) String() string {
+	return string(t.buf)
+}
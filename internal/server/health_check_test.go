@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startGRPCHealthServer serves the standard health service on a loopback
+// port until the test ends.
+func startGRPCHealthServer(t *testing.T) (*config.Backend, *health.Server) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return &config.Backend{URL: "http://" + lis.Addr().String()}, hs
+}
+
+func TestProbeGRPC(t *testing.T) {
+	b, hs := startGRPCHealthServer(t)
+	hs.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("payments", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	tests := []struct {
+		service string
+		healthy bool
+	}{
+		{"", true},
+		{"orders", true},
+		{"payments", false},
+		{"unknown", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			hc := config.HealthCheck{Enabled: true, Protocol: "grpc", Service: tt.service}
+			_, err := probe(context.Background(), b, hc)
+			if tt.healthy && err != nil {
+				t.Errorf("probe failed: %v", err)
+			}
+			if !tt.healthy && err == nil {
+				t.Error("probe passed, want failure")
+			}
+		})
+	}
+}
+
+func TestProbeGRPCServerShutdown(t *testing.T) {
+	b, hs := startGRPCHealthServer(t)
+	hc := config.HealthCheck{Enabled: true, Protocol: "grpc"}
+
+	if _, err := probe(context.Background(), b, hc); err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+
+	hs.Shutdown()
+	if _, err := probe(context.Background(), b, hc); err == nil {
+		t.Error("probe passed after shutdown, want failure")
+	}
+}
+
+func TestProbeGRPCUnreachable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	b := &config.Backend{URL: "http://" + addr}
+	hc := config.HealthCheck{Enabled: true, Protocol: "grpc", Timeout: defaultHealthCheckTimeout}
+	if _, err := probe(context.Background(), b, hc); err == nil {
+		t.Error("probe of a closed port passed, want failure")
+	}
+}
+
+func TestProbeExec(t *testing.T) {
+	b := &config.Backend{URL: "http://127.0.0.1:8080"}
+
+	tests := []struct {
+		name    string
+		command []string
+		healthy bool
+		output  string
+	}{
+		{"exit 0", []string{"sh", "-c", "echo ok"}, true, "ok\n"},
+		{"exit 1", []string{"sh", "-c", "echo down >&2; exit 1"}, false, "down\n"},
+		{"environment", []string{"sh", "-c", `test "$BACKEND_ADDR" = 127.0.0.1:9000 && echo $BACKEND_PORT`}, true, "9000\n"},
+		{"no command", nil, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hc := config.HealthCheck{Enabled: true, Protocol: "exec", Port: 9000, Command: tt.command}
+			output, err := probe(context.Background(), b, hc)
+			if tt.healthy && err != nil {
+				t.Errorf("probe failed: %v", err)
+			}
+			if !tt.healthy && err == nil {
+				t.Error("probe passed, want failure")
+			}
+			if output != tt.output {
+				t.Errorf("output = %q, want %q", output, tt.output)
+			}
+		})
+	}
+}
+
+func TestProbeExecTimeout(t *testing.T) {
+	b := &config.Backend{URL: "http://127.0.0.1:8080"}
+	hc := config.HealthCheck{Enabled: true, Protocol: "exec", Timeout: 100 * time.Millisecond, Command: []string{"sleep", "10"}}
+
+	start := time.Now()
+	if _, err := probe(context.Background(), b, hc); err == nil {
+		t.Error("probe passed, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("probe took %v, want it killed at the timeout", elapsed)
+	}
+}
+
+func TestHealthCheckDefaultProtocol(t *testing.T) {
+	for layer, want := range map[config.Layer]string{config.LayerFour: "tcp", config.LayerSeven: "http"} {
+		cfg := &config.Config{Layer: layer, Algorithm: config.AlgorithmRoundRobin}
+		lb, err := NewLoadBalancer(cfg, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := &config.Backend{URL: "http://backend-0"}
+		lb.SetBackends([]*config.Backend{b})
+		if got := lb.healthCheckFor(b).Protocol; got != want {
+			t.Errorf("%s: probing over %q, want %q", layer, got, want)
+		}
+	}
+}
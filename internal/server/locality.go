@@ -0,0 +1,72 @@
+package server
+
+import (
+	"math/rand/v2"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// preferLocal narrows the healthy backends down to the closest locality that
+// still has one: the load balancer's zone, then its region. With zone
+// weights configured, a zone is drawn by weight for every request instead.
+func (p *pool) preferLocal(healthy []*config.Backend) []*config.Backend {
+	locality := p.config.Locality
+
+	if len(locality.ZoneWeights) > 0 {
+		if zoned := pickWeightedZone(healthy, locality.ZoneWeights); len(zoned) > 0 {
+			return zoned
+		}
+		return healthy
+	}
+
+	if locality.Zone != "" {
+		if local := withLabel(healthy, config.LabelZone, locality.Zone); len(local) > 0 {
+			return local
+		}
+	}
+	if locality.Region != "" {
+		if local := withLabel(healthy, config.LabelRegion, locality.Region); len(local) > 0 {
+			return local
+		}
+	}
+	return healthy
+}
+
+// pickWeightedZone draws one of the weighted zones that has healthy backends
+// and returns those backends.
+func pickWeightedZone(healthy []*config.Backend, weights map[string]int) []*config.Backend {
+	zones := make(map[string][]*config.Backend)
+	for _, b := range healthy {
+		zone := b.Labels[config.LabelZone]
+		if weights[zone] > 0 {
+			zones[zone] = append(zones[zone], b)
+		}
+	}
+
+	total := 0
+	for zone := range zones {
+		total += weights[zone]
+	}
+	if total == 0 {
+		return nil
+	}
+
+	n := rand.IntN(total)
+	for zone, backends := range zones {
+		n -= weights[zone]
+		if n < 0 {
+			return backends
+		}
+	}
+	return nil
+}
+
+func withLabel(backends []*config.Backend, key, value string) []*config.Backend {
+	matched := make([]*config.Backend, 0, len(backends))
+	for _, b := range backends {
+		if b.Labels[key] == value {
+			matched = append(matched, b)
+		}
+	}
+	return matched
+}
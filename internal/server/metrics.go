@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/algorithms"
+	"github.com/shubhamojha1/heimdall/internal/metrics"
+)
+
+// Metrics returns a snapshot of the load balancer's global metrics.
+func (lb *LoadBalancer) Metrics() metrics.LoadBalancerMetrics {
+	m := metrics.LoadBalancerMetrics{
+		LastUpdated: time.Now(),
+	}
+
+	var failed int64
+	lb.mu.RLock()
+	m.BackendsTotal = len(lb.backends)
+	for _, b := range lb.backends {
+		if b.IsHealthy() {
+			m.BackendsAvailable++
+		}
+		if m.LastUpdated.Before(b.EjectedUntil) {
+			m.BackendsEjected++
+		}
+		m.ActiveConnections += atomic.LoadInt64(&b.Metrics.ActiveConnections)
+		m.TotalRequests += atomic.LoadInt64(&b.Metrics.RequestCount)
+		failed += atomic.LoadInt64(&b.Metrics.FailedRequests)
+	}
+
+	// the sticky table is per pool, report the combined size and the mean
+	// hit rate
+	tables := 0
+	for _, p := range lb.pools {
+		m.CircuitsOpen += p.breakers.tripped()
+
+		reporter, ok := p.balancer.(algorithms.MetricsReporter)
+		if !ok {
+			continue
+		}
+
+		var pm metrics.LoadBalancerMetrics
+		reporter.ReportMetrics(&pm)
+		m.StickyTableSize += pm.StickyTableSize
+		m.StickyHitRate += pm.StickyHitRate
+		tables++
+	}
+	lb.mu.RUnlock()
+
+	if tables > 0 {
+		m.StickyHitRate /= float64(tables)
+	}
+	if lb.retry != nil {
+		m.Retries = atomic.LoadInt64(&lb.retry.retries)
+		m.RetriesDenied = atomic.LoadInt64(&lb.retry.budgetDenied)
+	}
+	if m.TotalRequests > 0 {
+		m.ErrorRate = float64(failed) / float64(m.TotalRequests)
+	}
+	return m
+}
+
+func (lb *LoadBalancer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lb.Metrics())
+}
+
+// serveMetrics exposes Metrics as JSON on the configured metrics port until
+// the load balancer is stopped.
+func (lb *LoadBalancer) serveMetrics() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", lb.handleMetrics)
+
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", lb.Configuration.Metrics.Port),
+		Handler: mux,
+	}
+
+	go func() {
+		<-lb.StopChan
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		metricsServer.Shutdown(ctx)
+	}()
+
+	log.Printf("Serving metrics on port %d", lb.Configuration.Metrics.Port)
+	if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Metrics server failed: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+const (
+	defaultOutlierInterval       = 10 * time.Second
+	defaultOutlierFailurePercent = 50
+	defaultOutlierMinRequests    = 5
+	defaultBaseEjectionTime      = 30 * time.Second
+	defaultMaxEjectionTime       = 300 * time.Second
+	defaultMaxEjectionPercent    = 10
+)
+
+// outlierDetector counts every backend's failed requests over a sliding
+// window and decides when one should be ejected.
+type outlierDetector struct {
+	settings config.OutlierDetection
+
+	mu    sync.Mutex
+	stats map[string]*outlierStats // by backend URL
+}
+
+type outlierStats struct {
+	window slidingWindow
+
+	ejections    int       // ejections so far, doubles the next ejection time
+	ejectedUntil time.Time // end of the last ejection
+}
+
+// newOutlierDetector returns nil when outlier detection is disabled.
+func newOutlierDetector(settings config.OutlierDetection) *outlierDetector {
+	if !settings.Enabled {
+		return nil
+	}
+
+	if settings.Interval <= 0 {
+		settings.Interval = defaultOutlierInterval
+	}
+	if settings.FailurePercent <= 0 {
+		settings.FailurePercent = defaultOutlierFailurePercent
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = defaultOutlierMinRequests
+	}
+	if settings.BaseEjectionTime <= 0 {
+		settings.BaseEjectionTime = defaultBaseEjectionTime
+	}
+	if settings.MaxEjectionTime <= 0 {
+		settings.MaxEjectionTime = defaultMaxEjectionTime
+	}
+	if settings.MaxEjectionPercent <= 0 {
+		settings.MaxEjectionPercent = defaultMaxEjectionPercent
+	}
+
+	return &outlierDetector{
+		settings: settings,
+		stats:    make(map[string]*outlierStats),
+	}
+}
+
+// record adds the outcome of one request to b's window and reports whether
+// the window is now over the failure threshold.
+func (d *outlierDetector) record(b *config.Backend, failed bool, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s, ok := d.stats[b.URL]
+	if !ok {
+		s = &outlierStats{}
+		d.stats[b.URL] = s
+	}
+
+	s.window.add(d.settings.Interval, failed, now)
+	if !failed {
+		return false
+	}
+
+	requests, failures := s.window.counts(d.settings.Interval, now)
+	return requests >= d.settings.MinRequests &&
+		float64(failures)*100 >= d.settings.FailurePercent*float64(requests)
+}
+
+// eject starts a new ejection of b and returns how long it lasts. A backend
+// that has behaved for MaxEjectionTime since its last ejection starts over
+// at BaseEjectionTime. b must still be in the pool.
+func (d *outlierDetector) eject(b *config.Backend, now time.Time) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := d.stats[b.URL]
+	if now.Sub(s.ejectedUntil) > d.settings.MaxEjectionTime {
+		s.ejections = 0
+	}
+
+	duration := d.settings.BaseEjectionTime << s.ejections
+	if duration <= 0 || duration > d.settings.MaxEjectionTime {
+		duration = d.settings.MaxEjectionTime
+	} else {
+		s.ejections++
+	}
+
+	// the backend comes back with a clean window
+	s.window.reset()
+	s.ejectedUntil = now.Add(duration)
+	return duration
+}
+
+// prune drops the windows of backends that left the pool.
+func (d *outlierDetector) prune(backends []*config.Backend) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		current[b.URL] = true
+	}
+	for url := range d.stats {
+		if !current[url] {
+			delete(d.stats, url)
+		}
+	}
+}
+
+// observeOutcome feeds the result of a request or connection to b into the
+// pool's balancer, circuit breakers and outlier detection, ejecting b when
+// it crosses the threshold.
+func (lb *LoadBalancer) observeOutcome(p *pool, b *config.Backend, tok breakerToken, failed bool) {
+	if failed {
+		p.observeFailure(b)
+	}
+	p.breakers.record(b, tok, failed)
+	if p.outliers == nil {
+		return
+	}
+
+	now := time.Now()
+	if !p.outliers.record(b, failed, now) {
+		return
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	// a registry sync may have taken b out of the pool since, and its
+	// window with it
+	if !slices.Contains(p.backends, b) {
+		return
+	}
+	// requests still in flight may fail after the backend is ejected
+	if now.Before(b.EjectedUntil) {
+		return
+	}
+
+	ejected := 0
+	for _, other := range p.backends {
+		if now.Before(other.EjectedUntil) {
+			ejected++
+		}
+	}
+	if ejected > 0 && float64(ejected+1)*100 > p.outliers.settings.MaxEjectionPercent*float64(len(p.backends)) {
+		return
+	}
+
+	duration := p.outliers.eject(b, now)
+	b.EjectedUntil = now.Add(duration)
+	log.Printf("[Outlier] event=backend_ejected backend=%s pool=%s duration=%v", b.URL, p.name, duration)
+}
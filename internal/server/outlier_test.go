@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// newOutlierLB returns a layer 4 load balancer with n healthy backends in
+// its default pool.
+func newOutlierLB(t *testing.T, settings config.OutlierDetection, n int) (*LoadBalancer, *pool) {
+	t.Helper()
+
+	settings.Enabled = true
+	cfg := &config.Config{
+		Layer:            config.LayerFour,
+		Algorithm:        config.AlgorithmRoundRobin,
+		OutlierDetection: settings,
+	}
+	lb, err := NewLoadBalancer(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := make([]*config.Backend, n)
+	for i := range backends {
+		backends[i] = &config.Backend{URL: fmt.Sprintf("http://backend-%d", i), Enabled: true}
+		backends[i].Metrics.HealthCheckStatus = true
+	}
+	lb.SetBackends(backends)
+	return lb, lb.pools[config.DefaultPool]
+}
+
+func TestOutlierEjection(t *testing.T) {
+	lb, p := newOutlierLB(t, config.OutlierDetection{MinRequests: 5, BaseEjectionTime: time.Minute}, 2)
+	b := p.backends[0]
+
+	for i := 0; i < 4; i++ {
+		lb.observeOutcome(p, b, breakerToken{}, true)
+	}
+	if !b.IsServing() {
+		t.Fatal("ejected below MinRequests")
+	}
+
+	start := time.Now()
+	lb.observeOutcome(p, b, breakerToken{}, true)
+	if b.IsServing() {
+		t.Fatal("not ejected with every request failed")
+	}
+	if d := b.EjectedUntil.Sub(start); d < time.Minute || d > time.Minute+time.Second {
+		t.Errorf("ejected for %v, want BaseEjectionTime", d)
+	}
+	if !p.backends[1].IsServing() {
+		t.Error("healthy backend ejected")
+	}
+}
+
+func TestOutlierEjectionTimeDoubles(t *testing.T) {
+	d := newOutlierDetector(config.OutlierDetection{
+		Enabled:          true,
+		BaseEjectionTime: time.Second,
+		MaxEjectionTime:  5 * time.Second,
+	})
+	b := &config.Backend{URL: "http://a"}
+	now := time.Now()
+	d.record(b, true, now)
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := d.eject(b, now); got != want {
+			t.Fatalf("ejected for %v, want %v", got, want)
+		}
+	}
+
+	// a backend that behaved for MaxEjectionTime starts over
+	later := now.Add(11 * time.Second)
+	if got := d.eject(b, later); got != time.Second {
+		t.Fatalf("ejected for %v after behaving, want BaseEjectionTime", got)
+	}
+}
+
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	lb, p := newOutlierLB(t, config.OutlierDetection{MinRequests: 1, MaxEjectionPercent: 50}, 3)
+
+	for _, b := range p.backends {
+		lb.observeOutcome(p, b, breakerToken{}, true)
+	}
+
+	ejected := 0
+	for _, b := range p.backends {
+		if !b.IsServing() {
+			ejected++
+		}
+	}
+	if ejected != 1 {
+		t.Fatalf("%d of 3 backends ejected with max_ejection_percent 50, want 1", ejected)
+	}
+}
+
+func TestOutlierIgnoresRemovedBackend(t *testing.T) {
+	lb, p := newOutlierLB(t, config.OutlierDetection{MinRequests: 1}, 2)
+	b := p.backends[0]
+
+	// the backend leaves between its failure being recorded and ejected
+	p.outliers.record(b, true, time.Now())
+	lb.SetBackends([]*config.Backend{p.backends[1]})
+	lb.observeOutcome(p, b, breakerToken{}, true)
+
+	if !b.EjectedUntil.IsZero() {
+		t.Error("ejected a backend that left the pool")
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/shubhamojha1/heimdall/internal/algorithms"
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// pool is a named group of backends balanced by its own algorithm.
+type pool struct {
+	name     string
+	config   *config.Config // top level config with the pool's overrides
+	balancer algorithms.Balancer
+	backends []*config.Backend // guarded by LoadBalancer.mu
+
+	execSlots chan struct{}    // one slot per running exec health check
+	outliers  *outlierDetector // nil unless outlier detection is enabled
+	breakers  *circuitBreakers // nil unless circuit breaking is enabled
+}
+
+func newPool(cfg *config.Config, name string) (*pool, error) {
+	poolConfig := cfg.ForPool(name)
+	balancer, err := algorithms.New(poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("pool %s: %w", name, err)
+	}
+
+	maxExec := poolConfig.HealthCheck.MaxConcurrent
+	if maxExec <= 0 {
+		maxExec = defaultMaxConcurrentExec
+	}
+
+	return &pool{
+		name:      name,
+		config:    poolConfig,
+		balancer:  balancer,
+		execSlots: make(chan struct{}, maxExec),
+		outliers:  newOutlierDetector(poolConfig.OutlierDetection),
+		breakers:  newCircuitBreakers(poolConfig.CircuitBreaker),
+	}, nil
+}
+
+// healthy returns the pool's backends that can take traffic, leaving out
+// those in exclude. Tiers are added from priority 0 down until one has
+// enough healthy capacity by itself, so backups stay cold while the
+// primaries cope. Each tier is narrowed to its closest locality on its own,
+// so a local backup doesn't push out the primaries it only joins. Callers
+// hold LoadBalancer.mu.
+func (p *pool) healthy(exclude []*config.Backend) []*config.Backend {
+	tiers := make(map[int][]*config.Backend)
+	for _, b := range p.backends {
+		tiers[b.Priority] = append(tiers[b.Priority], b)
+	}
+	priorities := slices.Sorted(maps.Keys(tiers))
+
+	healthy := make([]*config.Backend, 0, len(p.backends))
+	for _, priority := range priorities {
+		total, up := 0.0, 0.0
+		var tier []*config.Backend
+		for _, b := range tiers[priority] {
+			total += b.EffectiveWeight()
+			if b.IsHealthy() && p.breakers.ready(b) {
+				tier = append(tier, b)
+				up += b.EffectiveWeight()
+			}
+		}
+		healthy = append(healthy, p.preferLocal(without(tier, exclude))...)
+
+		if up > 0 && up*100 >= p.config.Failover.MinHealthyPercent*total {
+			break
+		}
+	}
+	return healthy
+}
+
+// queueable returns the backends that would be healthy but for being at
+// MaxConns, preferring those with room left in their queue. Backends
+// without a queue count as full. Callers hold LoadBalancer.mu and
+// LoadBalancer.connMu, which guards QueueFull.
+func (p *pool) queueable() []*config.Backend {
+	var room, full []*config.Backend
+	for _, b := range p.backends {
+		if !b.IsServing() || !b.AtMaxConns() || !p.breakers.ready(b) {
+			continue
+		}
+		if b.MaxQueueSize == 0 || b.QueueFull {
+			full = append(full, b)
+		} else {
+			room = append(room, b)
+		}
+	}
+
+	if len(room) > 0 {
+		return room
+	}
+	// every queue is full, the backend picked turns the request away
+	return full
+}
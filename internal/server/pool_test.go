@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func zonedBackend(i, priority int, zone string, up bool) *config.Backend {
+	b := &config.Backend{
+		URL:      fmt.Sprintf("http://backend-%d", i),
+		Weight:   1,
+		Priority: priority,
+		Labels:   map[string]string{config.LabelZone: zone},
+		Enabled:  up,
+	}
+	b.Metrics.HealthCheckStatus = true
+	return b
+}
+
+func TestHealthyTiersAndZones(t *testing.T) {
+	cfg := &config.Config{Layer: config.LayerFour, Algorithm: config.AlgorithmRoundRobin}
+	cfg.Failover.MinHealthyPercent = 80
+	cfg.Locality.Zone = "a"
+
+	p, err := newPool(cfg, config.DefaultPool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 of 5 remote primaries are up, short of 80%, so the local backup
+	// joins them instead of replacing them
+	for i := 0; i < 5; i++ {
+		p.backends = append(p.backends, zonedBackend(i, 0, "b", i < 3))
+	}
+	backup := zonedBackend(5, 1, "a", true)
+	p.backends = append(p.backends, backup)
+
+	healthy := p.healthy(nil)
+	want := []*config.Backend{p.backends[0], p.backends[1], p.backends[2], backup}
+	if !slices.Equal(healthy, want) {
+		t.Fatalf("got %d backends, want the 3 healthy primaries and the backup", len(healthy))
+	}
+
+	// within a tier the local zone still wins
+	local := zonedBackend(6, 0, "a", true)
+	p.backends = append(p.backends, local)
+	for _, b := range p.backends[:5] {
+		b.Enabled = true
+	}
+	healthy = p.healthy(nil)
+	if !slices.Equal(healthy, []*config.Backend{local}) {
+		t.Fatalf("got %d backends, want only the local primary", len(healthy))
+	}
+
+	// excluded backends leave their tier's locality to the rest
+	healthy = p.healthy([]*config.Backend{local})
+	if len(healthy) != 5 || slices.Contains(healthy, backup) {
+		t.Fatalf("got %d backends, want the 5 remote primaries", len(healthy))
+	}
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/algorithms"
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// backendTransport sends each proxied request to a backend chosen by the
+// load balancer.
+type backendTransport struct {
+	lb        *LoadBalancer
+	transport http.RoundTripper
+}
+
+// RoundTrip sends req to a backend and, as the retry policy allows, tries
+// it again on other backends when that fails.
+func (t *backendTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retry := t.lb.retry
+	retryable, err := retry.allows(req)
+	if err != nil {
+		return nil, err
+	}
+
+	lbReq := algorithms.NewHTTPRequest(req)
+	poolName := t.lb.routePool(req)
+	p, backend, tok, err := t.lb.selectBackend(poolName, lbReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if retryable {
+		retry.budget.request()
+	}
+
+	tried := []*config.Backend{backend}
+	for attempt := 0; ; attempt++ {
+		resp, err := t.send(p, backend, tok, lbReq, req, attempt > 0)
+		if !retryable || attempt >= retry.attempts || req.Context().Err() != nil || !retry.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		next, nextBackend, nextTok, nextErr := t.lb.selectBackend(poolName, lbReq, tried...)
+		if nextErr != nil {
+			return resp, err
+		}
+		if !retry.budget.take() {
+			next.breakers.release(nextBackend, nextTok)
+			atomic.AddInt64(&retry.budgetDenied, 1)
+			return resp, err
+		}
+		atomic.AddInt64(&retry.retries, 1)
+
+		if err != nil {
+			log.Printf("[LB Server] Retrying %s %s on %s after %v", req.Method, req.URL.Path, nextBackend.URL, err)
+		} else {
+			log.Printf("[LB Server] Retrying %s %s on %s after status %d", req.Method, req.URL.Path, nextBackend.URL, resp.StatusCode)
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				next.breakers.release(nextBackend, nextTok)
+				return nil, err
+			}
+		}
+
+		p, backend, tok = next, nextBackend, nextTok
+		tried = append(tried, backend)
+	}
+}
+
+// send makes one attempt at req on backend.
+func (t *backendTransport) send(p *pool, backend *config.Backend, tok breakerToken, lbReq *algorithms.Request, req *http.Request, retry bool) (*http.Response, error) {
+	target, err := url.Parse(backend.URL)
+	if err != nil {
+		t.lb.observeOutcome(p, backend, tok, true)
+		return nil, fmt.Errorf("invalid backend url %q: %v", backend.URL, err)
+	}
+
+	done, err := t.lb.acquireConn(req.Context(), backend)
+	if err != nil {
+		p.breakers.release(backend, tok)
+		return nil, err
+	}
+
+	ctx, stopTimer, cancel := t.lb.retry.withPerTryTimeout(req)
+	out := req.Clone(ctx)
+	rewriteRequestURL(out, target)
+
+	start := time.Now()
+	atomic.AddInt64(&backend.Metrics.RequestCount, 1)
+	if retry {
+		atomic.AddInt64(&backend.Metrics.RetryCount, 1)
+	}
+	resp, err := t.transport.RoundTrip(out)
+	if stopTimer() && err != nil {
+		err = fmt.Errorf("%w: %v", errPerTryTimeout, err)
+	}
+	if err != nil {
+		cancel()
+		done()
+		t.finishRequest(p, backend, tok, req, err, false)
+		return nil, err
+	}
+	p.observeLatency(backend, config.MeasureFirstByte, time.Since(start))
+
+	switch {
+	case resp.StatusCode >= 500:
+		atomic.AddInt64(&backend.Metrics.Status5xx, 1)
+	case resp.StatusCode >= 400:
+		atomic.AddInt64(&backend.Metrics.Status4xx, 1)
+	}
+
+	if decorator, ok := p.balancer.(algorithms.ResponseDecorator); ok {
+		decorator.DecorateResponse(backend, lbReq, resp.Header)
+	}
+
+	// the request stays in flight until the response has been streamed
+	body := &trackedBody{ReadCloser: resp.Body}
+	body.done = func() {
+		cancel()
+		done()
+		p.observeLatency(backend, config.MeasureLastByte, time.Since(start))
+		t.finishRequest(p, backend, tok, req, body.err, resp.StatusCode >= 500)
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// finishRequest counts a finished request as failed or successful and
+// passes the outcome on to outlier detection. Requests the client gave up
+// on say nothing about the backend.
+func (t *backendTransport) finishRequest(p *pool, b *config.Backend, tok breakerToken, req *http.Request, err error, status5xx bool) {
+	if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+		p.breakers.release(b, tok)
+		return
+	}
+
+	failed := err != nil || status5xx
+	if failed {
+		atomic.AddInt64(&b.Metrics.FailedRequests, 1)
+	} else {
+		atomic.AddInt64(&b.Metrics.SuccessfulRequests, 1)
+	}
+	t.lb.observeOutcome(p, b, tok, failed)
+}
+
+// trackedBody ends the tracked request when the response body is closed.
+type trackedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+	err  error // first read error other than io.EOF, e.g. a reset
+}
+
+func (b *trackedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// observeLatency passes a latency sample on to balancers that learn from them.
+func (p *pool) observeLatency(b *config.Backend, measure string, latency time.Duration) {
+	if observer, ok := p.balancer.(algorithms.LatencyObserver); ok {
+		observer.ObserveLatency(b, measure, latency)
+	}
+}
+
+// observeFailure tells balancers that learn from failures about one.
+func (p *pool) observeFailure(b *config.Backend) {
+	if observer, ok := p.balancer.(algorithms.FailureObserver); ok {
+		observer.ObserveFailure(b)
+	}
+}
+
+func (lb *LoadBalancer) newReverseProxy() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// keep the chain built by proxies in front of us
+			pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+			pr.SetXForwarded()
+		},
+		Transport: &backendTransport{
+			lb:        lb,
+			transport: http.DefaultTransport,
+		},
+		// stream responses to the client as soon as the backend writes them
+		FlushInterval: -1,
+		ErrorHandler:  lb.handleProxyError,
+	}
+}
+
+func (lb *LoadBalancer) handleProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("[LB Server] Failed to proxy %s %s: %v", r.Method, r.URL.Path, err)
+	if errors.Is(err, errQueueFull) || errors.Is(err, errQueueTimeout) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+// rewriteRequestURL points req at target, joining the target's base path with
+// the request path.
+func rewriteRequestURL(req *http.Request, target *url.URL) {
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.Host = ""
+	if target.Path != "" {
+		req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
+		req.URL.RawPath = ""
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// how long a request waits in a backend's queue when it has no QueueTimeout
+const defaultQueueTimeout = 30 * time.Second
+
+// weight of the newest sample in the QueueTime moving average
+const queueTimeDecay = 0.2
+
+var (
+	errQueueFull    = errors.New("backend queue is full")
+	errQueueTimeout = errors.New("timed out waiting in backend queue")
+)
+
+// acquireConn counts a request or connection against b's MaxConns and
+// returns the function that ends it. When b is at MaxConns the request
+// waits in b's FIFO queue, up to MaxQueueSize requests long, until a
+// connection is handed over to it or QueueTimeout passes.
+func (lb *LoadBalancer) acquireConn(ctx context.Context, b *config.Backend) (func(), error) {
+	lb.mu.RLock()
+	maxConns, maxQueue, timeout := int64(b.MaxConns), b.MaxQueueSize, b.QueueTimeout
+	lb.mu.RUnlock()
+
+	lb.connMu.Lock()
+	waiters := lb.queues[b.URL]
+	if waiters == nil && (maxConns == 0 || atomic.LoadInt64(&b.Metrics.ActiveConnections) < maxConns) {
+		atomic.AddInt64(&b.Metrics.ActiveConnections, 1)
+		lb.connMu.Unlock()
+		return lb.connStarted(b), nil
+	}
+
+	if waiters == nil {
+		if maxQueue <= 0 {
+			lb.connMu.Unlock()
+			atomic.AddInt64(&b.Metrics.DroppedConnections, 1)
+			return nil, errQueueFull
+		}
+		waiters = list.New()
+		lb.queues[b.URL] = waiters
+	} else if waiters.Len() >= maxQueue {
+		lb.connMu.Unlock()
+		atomic.AddInt64(&b.Metrics.DroppedConnections, 1)
+		return nil, errQueueFull
+	}
+
+	handoff := make(chan struct{})
+	waiter := waiters.PushBack(handoff)
+	atomic.AddInt64(&b.Metrics.QueuedConnections, 1)
+	b.QueueFull = waiters.Len() >= maxQueue
+	lb.connMu.Unlock()
+
+	if timeout <= 0 {
+		timeout = defaultQueueTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	start := time.Now()
+	var err error
+	select {
+	case <-handoff:
+	case <-timer.C:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	lb.connMu.Lock()
+	if err != nil {
+		select {
+		case <-handoff:
+			// a connection was handed over as we gave up, take it after all
+			err = nil
+		default:
+			waiters.Remove(waiter)
+			if waiters.Len() == 0 {
+				delete(lb.queues, b.URL)
+			}
+		}
+	}
+	atomic.AddInt64(&b.Metrics.QueuedConnections, -1)
+	// the waiter is off the queue either way, whether it gave up or the
+	// connection handing it a slot took it off
+	b.QueueFull = waiters.Len() >= maxQueue
+	lb.observeQueueTime(b, time.Since(start))
+	lb.connMu.Unlock()
+
+	if err != nil {
+		if errors.Is(err, errQueueTimeout) {
+			atomic.AddInt64(&b.Metrics.DroppedConnections, 1)
+		}
+		return nil, err
+	}
+	return lb.connStarted(b), nil
+}
+
+// connStarted counts a connection that holds one of b's slots and returns
+// the function that hands the slot on to the oldest queued request, or
+// frees it.
+func (lb *LoadBalancer) connStarted(b *config.Backend) func() {
+	atomic.AddInt64(&b.Metrics.TotalConnections, 1)
+
+	return func() {
+		lb.connMu.Lock()
+		defer lb.connMu.Unlock()
+
+		waiters := lb.queues[b.URL]
+		if waiters == nil {
+			atomic.AddInt64(&b.Metrics.ActiveConnections, -1)
+			return
+		}
+		close(waiters.Remove(waiters.Front()).(chan struct{}))
+		if waiters.Len() == 0 {
+			delete(lb.queues, b.URL)
+		}
+	}
+}
+
+// observeQueueTime folds a queue wait into b's QueueTime average. Callers
+// hold lb.connMu.
+func (lb *LoadBalancer) observeQueueTime(b *config.Backend, wait time.Duration) {
+	avg := time.Duration(atomic.LoadInt64((*int64)(&b.Metrics.QueueTime)))
+	if avg == 0 {
+		avg = wait
+	} else {
+		avg += time.Duration(queueTimeDecay * float64(wait-avg))
+	}
+	atomic.StoreInt64((*int64)(&b.Metrics.QueueTime), int64(avg))
+}
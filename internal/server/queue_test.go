@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// newQueueLB returns a layer 7 load balancer with a single healthy backend
+// that takes one connection at a time.
+func newQueueLB(t *testing.T, maxQueue int, timeout time.Duration) (*LoadBalancer, *config.Backend) {
+	t.Helper()
+
+	cfg := &config.Config{Layer: config.LayerSeven, Algorithm: config.AlgorithmRoundRobin}
+	lb, err := NewLoadBalancer(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &config.Backend{
+		URL:          "http://backend-0",
+		Enabled:      true,
+		MaxConns:     1,
+		MaxQueueSize: maxQueue,
+		QueueTimeout: timeout,
+	}
+	b.Metrics.HealthCheckStatus = true
+	lb.SetBackends([]*config.Backend{b})
+	return lb, b
+}
+
+// waitQueued waits until n requests are queued on b.
+func waitQueued(t *testing.T, b *config.Backend, n int64) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&b.Metrics.QueuedConnections) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests queued, want %d", atomic.LoadInt64(&b.Metrics.QueuedConnections), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueHandoffIsFIFO(t *testing.T) {
+	lb, b := newQueueLB(t, 2, time.Minute)
+
+	done, err := lb.acquireConn(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan int, 2)
+	releases := make(chan func(), 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			release, err := lb.acquireConn(context.Background(), b)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			order <- i
+			releases <- release
+		}()
+		waitQueued(t, b, int64(i+1))
+	}
+
+	// a third request finds the queue full
+	if _, err := lb.acquireConn(context.Background(), b); !errors.Is(err, errQueueFull) {
+		t.Fatalf("got %v with the queue full, want errQueueFull", err)
+	}
+	if dropped := atomic.LoadInt64(&b.Metrics.DroppedConnections); dropped != 1 {
+		t.Errorf("%d dropped connections, want 1", dropped)
+	}
+
+	// each release hands the slot to the oldest waiter
+	done()
+	if got := <-order; got != 0 {
+		t.Fatalf("waiter %d got the slot first, want 0", got)
+	}
+	(<-releases)()
+	if got := <-order; got != 1 {
+		t.Fatalf("waiter %d got the slot second, want 1", got)
+	}
+	(<-releases)()
+
+	if active := atomic.LoadInt64(&b.Metrics.ActiveConnections); active != 0 {
+		t.Errorf("%d active connections after every release, want 0", active)
+	}
+	if total := atomic.LoadInt64(&b.Metrics.TotalConnections); total != 3 {
+		t.Errorf("%d total connections, want 3", total)
+	}
+	if queued := atomic.LoadInt64(&b.Metrics.QueuedConnections); queued != 0 {
+		t.Errorf("%d requests still queued, want 0", queued)
+	}
+	if b.Metrics.QueueTime <= 0 {
+		t.Error("queue wait not recorded in QueueTime")
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	lb, b := newQueueLB(t, 1, 10*time.Millisecond)
+
+	done, err := lb.acquireConn(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	start := time.Now()
+	if _, err := lb.acquireConn(context.Background(), b); !errors.Is(err, errQueueTimeout) {
+		t.Fatalf("got %v, want errQueueTimeout", err)
+	}
+	if waited := time.Since(start); waited < 10*time.Millisecond {
+		t.Errorf("gave up after %v, before QueueTimeout", waited)
+	}
+	if dropped := atomic.LoadInt64(&b.Metrics.DroppedConnections); dropped != 1 {
+		t.Errorf("%d dropped connections, want 1", dropped)
+	}
+	if queued := atomic.LoadInt64(&b.Metrics.QueuedConnections); queued != 0 {
+		t.Errorf("%d requests still queued, want 0", queued)
+	}
+	if b.QueueFull {
+		t.Error("queue still marked full after the waiter gave up")
+	}
+}
+
+func TestSaturatedWithoutQueue(t *testing.T) {
+	lb, b := newQueueLB(t, 0, 0)
+
+	done, err := lb.acquireConn(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	// a backend at MaxConns with nowhere to queue turns the request away as
+	// overloaded rather than as missing
+	rec := httptest.NewRecorder()
+	lb.HandleHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", rec.Code)
+	}
+	if dropped := atomic.LoadInt64(&b.Metrics.DroppedConnections); dropped != 1 {
+		t.Errorf("%d dropped connections, want 1", dropped)
+	}
+}
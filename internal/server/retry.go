@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+const (
+	defaultRetryBudgetPercent = 20
+	defaultRetryMaxBodyBytes  = 64 << 10
+
+	// the retry budget is kept over this window, and this many retries
+	// are allowed in it whatever the traffic
+	retryBudgetInterval = 10 * time.Second
+	minRetryBudget      = 3
+)
+
+var errPerTryTimeout = errors.New("per try timeout")
+
+// retryPolicy decides whether a failed attempt is tried again on another
+// backend.
+type retryPolicy struct {
+	attempts         int
+	perTryTimeout    time.Duration
+	nonIdempotent    bool
+	maxBodyBytes     int64
+	onConnectFailure bool
+	onTimeout        bool
+	onStatus         map[int]bool
+
+	budget retryBudget
+
+	// counters for Metrics, updated atomically
+	retries      int64
+	budgetDenied int64
+}
+
+// newRetryPolicy returns nil when retries are turned off.
+func newRetryPolicy(settings config.RetrySettings) (*retryPolicy, error) {
+	if settings.Attempts <= 0 {
+		return nil, nil
+	}
+
+	on := settings.On
+	if len(on) == 0 {
+		on = []string{config.RetryOnConnectFailure, config.RetryOnTimeout, "502", "503", "504"}
+	}
+
+	r := &retryPolicy{
+		attempts:      settings.Attempts,
+		perTryTimeout: settings.PerTryTimeout,
+		nonIdempotent: settings.NonIdempotent,
+		maxBodyBytes:  settings.MaxBodyBytes,
+		onStatus:      make(map[int]bool),
+		budget:        retryBudget{percent: settings.BudgetPercent},
+	}
+	if r.budget.percent <= 0 {
+		r.budget.percent = defaultRetryBudgetPercent
+	}
+	if r.maxBodyBytes <= 0 {
+		r.maxBodyBytes = defaultRetryMaxBodyBytes
+	}
+
+	for _, cond := range on {
+		switch cond {
+		case config.RetryOnConnectFailure:
+			r.onConnectFailure = true
+		case config.RetryOnTimeout:
+			r.onTimeout = true
+		case "502", "503", "504":
+			status, _ := strconv.Atoi(cond)
+			r.onStatus[status] = true
+		default:
+			return nil, fmt.Errorf("unknown retry condition %q", cond)
+		}
+	}
+	return r, nil
+}
+
+// allows reports whether req may be retried at all: its method has to be
+// idempotent, unless configured otherwise, and its body has to be
+// replayable. Bodies up to maxBodyBytes are buffered to make them so.
+func (r *retryPolicy) allows(req *http.Request) (bool, error) {
+	if r == nil {
+		return false, nil
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		if !r.nonIdempotent {
+			return false, nil
+		}
+	}
+
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return true, nil
+	}
+	if req.ContentLength > r.maxBodyBytes {
+		return false, nil
+	}
+
+	body := req.Body
+	buf, err := io.ReadAll(io.LimitReader(body, r.maxBodyBytes+1))
+	if err != nil {
+		return false, fmt.Errorf("reading request body: %w", err)
+	}
+	if int64(len(buf)) > r.maxBodyBytes {
+		// too large to keep, send what was read followed by the rest
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), body), body}
+		return false, nil
+	}
+
+	body.Close()
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return true, nil
+}
+
+// shouldRetry reports whether the outcome of an attempt is worth a retry.
+func (r *retryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err == nil {
+		return r.onStatus[resp.StatusCode]
+	}
+
+	var netErr net.Error
+	if errors.Is(err, errPerTryTimeout) || errors.Is(err, errQueueTimeout) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return r.onTimeout
+	}
+
+	// the request never reached the backend
+	var opErr *net.OpError
+	if errors.Is(err, errQueueFull) || (errors.As(err, &opErr) && opErr.Op == "dial") {
+		return r.onConnectFailure
+	}
+	return false
+}
+
+// retryBudget caps retries at a share of the requests over a sliding
+// window. The window counts retries as its failures.
+type retryBudget struct {
+	percent float64
+
+	mu     sync.Mutex
+	window slidingWindow
+}
+
+// request counts a request that came in from a client.
+func (b *retryBudget) request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.window.add(retryBudgetInterval, false, time.Now())
+}
+
+// take spends one retry, if the budget has room for it.
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	requests, retries := b.window.counts(retryBudgetInterval, now)
+	allowed := max(minRetryBudget, b.percent/100*float64(requests-retries))
+	if float64(retries+1) > allowed {
+		return false
+	}
+	b.window.add(retryBudgetInterval, true, now)
+	return true
+}
+
+// withPerTryTimeout returns the context for one attempt at req. stop ends
+// the per try timer once the response headers are in and reports whether
+// it had already fired; cancel releases the context after the response.
+func (r *retryPolicy) withPerTryTimeout(req *http.Request) (ctx context.Context, stop func() bool, cancel func()) {
+	ctx, cancel = context.WithCancel(req.Context())
+	if r == nil || r.perTryTimeout <= 0 {
+		return ctx, func() bool { return false }, cancel
+	}
+
+	timer := time.AfterFunc(r.perTryTimeout, cancel)
+	return ctx, func() bool { return !timer.Stop() }, cancel
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func TestRetryBudget(t *testing.T) {
+	var budget retryBudget
+	budget.percent = 20
+
+	// a few retries are always allowed
+	for i := 0; i < minRetryBudget; i++ {
+		if !budget.take() {
+			t.Fatalf("retry %d denied below the minimum budget", i+1)
+		}
+	}
+	if budget.take() {
+		t.Fatal("retry allowed past the minimum budget without requests")
+	}
+
+	// beyond that, at most 20% of the requests
+	budget = retryBudget{percent: 20}
+	for i := 0; i < 100; i++ {
+		budget.request()
+	}
+	for i := 0; i < 20; i++ {
+		if !budget.take() {
+			t.Fatalf("retry %d of 20 denied with 100 requests", i+1)
+		}
+	}
+	if budget.take() {
+		t.Fatal("retry 21 allowed with 100 requests and a 20% budget")
+	}
+}
+
+func TestRetryOnAnotherBackend(t *testing.T) {
+	var failed int64
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&failed, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer good.Close()
+
+	cfg := &config.Config{
+		Layer:     config.LayerSeven,
+		Algorithm: config.AlgorithmRoundRobin,
+		LayerConfig: config.L7Settings{
+			// enough budget to retry every request
+			Retry: config.RetrySettings{Attempts: 1, NonIdempotent: true, BudgetPercent: 100},
+		},
+	}
+	lb, err := NewLoadBalancer(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := []*config.Backend{{URL: bad.URL}, {URL: good.URL}}
+	for _, b := range backends {
+		b.Enabled = true
+		b.Metrics.HealthCheckStatus = true
+	}
+	lb.SetBackends(backends)
+
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("order"))
+		rec := httptest.NewRecorder()
+		lb.HandleHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || rec.Body.String() != "order" {
+			t.Fatalf("request %d: status %d, body %q; want 200 with the request body", i, rec.Code, rec.Body.String())
+		}
+	}
+	attempts := atomic.LoadInt64(&failed)
+	if attempts == 0 {
+		t.Error("no request reached the failing backend")
+	}
+	if retries := atomic.LoadInt64(&lb.retry.retries); retries != attempts {
+		t.Errorf("%d retries for %d failed attempts", retries, attempts)
+	}
+}
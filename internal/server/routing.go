@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// routingRule is a config.ContentRule with its path regex compiled.
+type routingRule struct {
+	config.ContentRule
+	pathRegex *regexp.Regexp
+}
+
+// compileRoutingRules compiles the rules and orders them by priority.
+func compileRoutingRules(rules []config.ContentRule) ([]routingRule, error) {
+	compiled := make([]routingRule, 0, len(rules))
+	for i, rule := range rules {
+		r := routingRule{ContentRule: rule}
+		if rule.PathRegex != "" {
+			re, err := regexp.Compile(rule.PathRegex)
+			if err != nil {
+				return nil, fmt.Errorf("routing rule %d: invalid path_regex: %w", i, err)
+			}
+			r.pathRegex = re
+		}
+		compiled = append(compiled, r)
+	}
+
+	sort.SliceStable(compiled, func(i, j int) bool {
+		return compiled[i].Priority > compiled[j].Priority
+	})
+	return compiled, nil
+}
+
+// routePool returns the pool of the first rule matching req, or the
+// listener's pool.
+func (lb *LoadBalancer) routePool(req *http.Request) string {
+	for _, rule := range lb.rules {
+		if rule.matches(req) {
+			return rule.Pool
+		}
+	}
+	return lb.Configuration.ListenPool()
+}
+
+func (r *routingRule) matches(req *http.Request) bool {
+	if r.Host != "" && !hostMatches(r.Host, req.Host) {
+		return false
+	}
+	if r.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, r.PathPrefix) {
+		return false
+	}
+	if r.pathRegex != nil && !r.pathRegex.MatchString(req.URL.Path) {
+		return false
+	}
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(m string) bool {
+		return strings.EqualFold(m, req.Method)
+	}) {
+		return false
+	}
+
+	for name, want := range r.Headers {
+		values := req.Header.Values(name)
+		if len(values) == 0 || (want != "" && !slices.Contains(values, want)) {
+			return false
+		}
+	}
+
+	if len(r.Query) > 0 {
+		query := req.URL.Query()
+		for name, want := range r.Query {
+			values, ok := query[name]
+			if !ok || (want != "" && !slices.Contains(values, want)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hostMatches compares a rule host with the request's Host header, ignoring
+// case and port. A leading "*." matches any subdomain.
+func hostMatches(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(host, suffix)
+	}
+	return host == pattern
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+func TestRoutePool(t *testing.T) {
+	var l7 config.L7Settings
+	l7.Routing.Rules = []config.ContentRule{
+		{Priority: 1, PathPrefix: "/api", Pool: "api"},
+		// higher priority, so it wins over the /api prefix
+		{Priority: 10, PathPrefix: "/api/admin", Pool: "admin"},
+		{Host: "*.static.example.com", Pool: "static"},
+		{Host: "example.com", PathRegex: `^/orders/[0-9]+$`, Pool: "orders"},
+		{PathPrefix: "/upload", Methods: []string{"post", "PUT"}, Pool: "uploads"},
+		{Headers: map[string]string{"X-Canary": "1"}, Pool: "canary"},
+		{Headers: map[string]string{"X-Debug": ""}, Pool: "debug"},
+		{Query: map[string]string{"version": "beta"}, Pool: "beta"},
+	}
+
+	cfg := &config.Config{Layer: config.LayerSeven, Algorithm: config.AlgorithmRoundRobin, LayerConfig: l7}
+	cfg.Listen.Pool = "web"
+	lb, err := NewLoadBalancer(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		want    string
+	}{
+		{"path prefix", "GET", "http://example.com/api/users", nil, "api"},
+		{"priority", "GET", "http://example.com/api/admin/users", nil, "admin"},
+		{"host wildcard", "GET", "http://img.static.example.com/logo.png", nil, "static"},
+		{"host wildcard ignores case and port", "GET", "http://IMG.Static.Example.com:8080/logo.png", nil, "static"},
+		{"host wildcard needs a subdomain", "GET", "http://static.example.com/logo.png", nil, "web"},
+		{"path regex", "GET", "http://example.com/orders/42", nil, "orders"},
+		{"path regex on another host", "GET", "http://shop.com/orders/42", nil, "web"},
+		{"path regex mismatch", "GET", "http://example.com/orders/new", nil, "web"},
+		{"method", "POST", "http://example.com/upload", nil, "uploads"},
+		{"method mismatch", "GET", "http://example.com/upload", nil, "web"},
+		{"header value", "GET", "http://example.com/", map[string]string{"X-Canary": "1"}, "canary"},
+		{"header value mismatch", "GET", "http://example.com/", map[string]string{"X-Canary": "0"}, "web"},
+		{"header present", "GET", "http://example.com/", map[string]string{"X-Debug": "anything"}, "debug"},
+		{"query", "GET", "http://example.com/?version=beta", nil, "beta"},
+		{"query mismatch", "GET", "http://example.com/?version=stable", nil, "web"},
+		{"fallback", "GET", "http://example.com/", nil, "web"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if got := lb.routePool(req); got != tt.want {
+				t.Errorf("routed to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
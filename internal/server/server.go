@@ -1,15 +1,29 @@
 package server
 
 import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"net/http/httputil"
 	"os"
+	"slices"
 	"sync"
+	"time"
 
+	"github.com/shubhamojha1/heimdall/internal/algorithms"
 	"github.com/shubhamojha1/heimdall/internal/config"
 )
 
+// how often the load balancer pulls the backend list from the service registry
+const registrySyncInterval = 2 * time.Second
+
+// registryClient gives up on a registry that hasn't answered by the next sync
+var registryClient = &http.Client{Timeout: registrySyncInterval}
+
 // type ServiceRegistry struct {
 // 	Backends       []*config.Backend
 // 	HealthChecks   []*config.HealthCheck
@@ -21,24 +35,284 @@ type LoadBalancer struct {
 	Configuration *config.Config
 	// LayerConfig     interface{} `json"-"`
 	// ServiceRegistry *registry.ServiceRegistry (implementing as a separate process)
-	StopChan chan struct{} // load balancer ka channel
-	listener http.Server   // for clients outside the network to connect to a server via the load balancer
+	StopChan    chan struct{} // load balancer ka channel
+	listener    http.Server   // for clients outside the network to connect to a server via the load balancer
+	RegistryURL string        // base URL of the service registry, e.g. http://localhost:10000
+
+	backends []*config.Backend // latest snapshot of the registry's backends
+	pools    map[string]*pool
+	rules    []routingRule
+	proxy    *httputil.ReverseProxy
+	retry    *retryPolicy // nil unless retries are configured
+
+	tcpListener net.Listener  // layer 4 listener
+	tcpSlots    chan struct{} // one slot per open layer 4 connection
+
+	connMu sync.Mutex            // guards queues and every backend's QueueFull
+	queues map[string]*list.List // backend URL -> requests waiting for a connection, see acquireConn
+}
+
+func NewLoadBalancer(configuration *config.Config, registryURL string) (*LoadBalancer, error) {
+	rules, err := compileRoutingRules(configuration.L7().Routing.Rules)
+	if err != nil {
+		return nil, err
+	}
+	retry, err := newRetryPolicy(configuration.L7().Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	lb := &LoadBalancer{
+		Configuration: configuration,
+		RegistryURL:   registryURL,
+		StopChan:      make(chan struct{}),
+		pools:         make(map[string]*pool),
+		queues:        make(map[string]*list.List),
+		rules:         rules,
+		retry:         retry,
+	}
+
+	// build every pool the config names up front so errors surface here
+	names := []string{config.DefaultPool, configuration.ListenPool()}
+	for _, p := range configuration.Pools {
+		names = append(names, p.Name)
+	}
+	for _, rule := range rules {
+		names = append(names, rule.Pool)
+	}
+	for _, name := range names {
+		if _, ok := lb.pools[name]; ok {
+			continue
+		}
+		p, err := newPool(configuration, name)
+		if err != nil {
+			return nil, err
+		}
+		lb.pools[name] = p
+	}
+
+	lb.proxy = lb.newReverseProxy()
+	return lb, nil
 }
 
 func (lb *LoadBalancer) handleConnection(clientConn net.Conn) {
 	defer clientConn.Close()
 
-	// if lb.Configuration.Algorithm == config.AlgorithmRoundRobin {
-	// 	backendAddrs := lb.selectBackend()
-	// 	algorithms.handleConnectionRoundRobin(lb, clientConn)
-	// }
-	// return nil
+	p, backend, tok, err := lb.selectBackend(lb.Configuration.ListenPool(), algorithms.NewConnRequest(clientConn))
+	if err != nil {
+		log.Printf("[LB Server] Dropping connection from %s: %v", clientConn.RemoteAddr(), err)
+		return
+	}
+
+	done, err := lb.acquireConn(context.Background(), backend)
+	if err != nil {
+		p.breakers.release(backend, tok)
+		log.Printf("[LB Server] Dropping connection from %s: %v", clientConn.RemoteAddr(), err)
+		return
+	}
+	defer done()
+
+	addr, err := backendAddress(backend)
+	if err != nil {
+		lb.observeOutcome(p, backend, tok, true)
+		log.Printf("[LB Server] %v", err)
+		return
+	}
+
+	lb.mu.RLock()
+	timeout := backend.ConnectionTimeout
+	lb.mu.RUnlock()
+
+	dialer := net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: lb.keepAlivePeriod(),
+	}
+	start := time.Now()
+	backendConn, err := dialer.Dial("tcp", addr)
+	lb.observeOutcome(p, backend, tok, err != nil)
+	if err != nil {
+		log.Printf("[LB Server] Failed to connect to backend %s: %v", addr, err)
+		return
+	}
+	defer backendConn.Close()
+
+	if _, ok := p.balancer.(algorithms.LatencyObserver); ok {
+		backendConn = &firstByteConn{Conn: backendConn, onFirstByte: func() {
+			p.observeLatency(backend, config.MeasureFirstByte, time.Since(start))
+		}}
+		defer func() {
+			p.observeLatency(backend, config.MeasureLastByte, time.Since(start))
+		}()
+	}
+
+	splice(clientConn, backendConn)
 }
 
 func (lb *LoadBalancer) HandleHTTP(w http.ResponseWriter, r *http.Request) {
-	log.Println("[LB Server] Handling HTTP request...")
+	lb.proxy.ServeHTTP(w, r)
+}
+
+// selectBackend lets the pool's algorithm pick one of its healthy backends
+// and passes the request through the backend's circuit breaker. When every
+// backend is at MaxConns it picks one to queue on instead, see acquireConn,
+// which turns the request away when that backend has no room to queue it.
+// Backends in exclude, such as those a retried request already failed on,
+// are skipped. The caller reports how the request went with observeOutcome,
+// or hands back the breaker token with release.
+func (lb *LoadBalancer) selectBackend(poolName string, req *algorithms.Request, exclude ...*config.Backend) (*pool, *config.Backend, breakerToken, error) {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	p, ok := lb.pools[poolName]
+	if !ok {
+		return nil, nil, breakerToken{}, fmt.Errorf("%w in pool %s", algorithms.ErrNoBackend, poolName)
+	}
+
+	candidates := p.healthy(exclude)
+	if len(candidates) == 0 {
+		lb.connMu.Lock()
+		queueable := p.queueable()
+		lb.connMu.Unlock()
+		candidates = p.preferLocal(without(queueable, exclude))
+	}
+	for {
+		backend, err := p.balancer.Pick(candidates, req)
+		if err != nil {
+			return nil, nil, breakerToken{}, fmt.Errorf("pool %s: %w", poolName, err)
+		}
+		if tok, ok := p.breakers.acquire(backend); ok {
+			return p, backend, tok, nil
+		}
 
-	w.Write([]byte("Hello from load balancer!"))
+		// another request took the last half-open probe slot
+		candidates = without(candidates, []*config.Backend{backend})
+	}
+}
+
+// without returns the backends that are not in exclude, leaving backends
+// untouched.
+func without(backends, exclude []*config.Backend) []*config.Backend {
+	if len(exclude) == 0 {
+		return backends
+	}
+	return slices.DeleteFunc(slices.Clone(backends), func(b *config.Backend) bool {
+		return slices.Contains(exclude, b)
+	})
+}
+
+// SetBackends replaces the backend snapshot with the one reported by the
+// registry. Backends that are already known keep their pointer, and with it
+// the metrics the load balancer has collected for them.
+func (lb *LoadBalancer) SetBackends(latest []*config.Backend) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	known := make(map[string]*config.Backend, len(lb.backends))
+	for _, b := range lb.backends {
+		known[b.URL] = b
+	}
+
+	now := time.Now()
+	merged := make([]*config.Backend, 0, len(latest))
+	for _, b := range latest {
+		if existing, ok := known[b.URL]; ok {
+			wasUp := isUp(existing)
+			existing.ApplySettings(b)
+			// with active checks the probes own the health status,
+			// otherwise go by the registry's heartbeats
+			if !lb.healthCheckFor(existing).Enabled {
+				existing.Metrics.HealthCheckStatus = b.Metrics.HealthCheckStatus
+			}
+			existing.Metrics.CPUUsage = b.Metrics.CPUUsage
+			existing.Metrics.MemoryUsage = b.Metrics.MemoryUsage
+			if !wasUp && isUp(existing) {
+				existing.WarmingSince = now
+			}
+			merged = append(merged, existing)
+			continue
+		}
+		b.WarmingSince = now
+		merged = append(merged, b)
+	}
+	lb.backends = merged
+
+	for _, p := range lb.pools {
+		p.backends = p.backends[:0]
+	}
+	for _, b := range merged {
+		p, ok := lb.pools[b.PoolName()]
+		if !ok {
+			var err error
+			if p, err = newPool(lb.Configuration, b.PoolName()); err != nil {
+				log.Printf("Ignoring backend %s: %v", b.URL, err)
+				continue
+			}
+			lb.pools[p.name] = p
+		}
+		b.SlowStart = p.config.SlowStart
+		p.backends = append(p.backends, b)
+	}
+	for _, p := range lb.pools {
+		if p.outliers != nil {
+			p.outliers.prune(p.backends)
+		}
+		p.breakers.prune(p.backends)
+		if observer, ok := p.balancer.(algorithms.MembershipObserver); ok {
+			observer.ObserveBackends(p.backends)
+		}
+	}
+}
+
+// isUp reports whether b is enabled and passing health checks, ignoring
+// the load based limits of IsHealthy. Slow start begins when it turns true.
+func isUp(b *config.Backend) bool {
+	return b.Enabled && !b.MaintenanceMode && b.Metrics.HealthCheckStatus
+}
+
+func (lb *LoadBalancer) fetchBackends() ([]*config.Backend, error) {
+	resp, err := registryClient.Get(lb.RegistryURL + "/backends")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned status code %d", resp.StatusCode)
+	}
+
+	var backends []*config.Backend
+	if err := json.NewDecoder(resp.Body).Decode(&backends); err != nil {
+		return nil, fmt.Errorf("failed to decode backends: %w", err)
+	}
+
+	// durations are registered in seconds, as in config.json
+	for _, b := range backends {
+		b.QueueTimeout *= time.Second
+		b.ConnectionTimeout *= time.Second
+	}
+	return backends, nil
+}
+
+// syncBackends keeps the backend snapshot in line with the service registry
+// until the load balancer is stopped.
+func (lb *LoadBalancer) syncBackends() {
+	ticker := time.NewTicker(registrySyncInterval)
+	defer ticker.Stop()
+
+	for {
+		backends, err := lb.fetchBackends()
+		if err != nil {
+			log.Printf("Failed to fetch backends from registry: %v", err)
+		} else {
+			lb.SetBackends(backends)
+		}
+
+		select {
+		case <-lb.StopChan:
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 func (lb *LoadBalancer) Start() error {
@@ -47,18 +321,19 @@ func (lb *LoadBalancer) Start() error {
 	// lbChannel := make(lb.stopChan)
 	// add additional checks later
 	// listen for new client requests
+	if lb.Configuration.Layer == config.LayerFour {
+		if err := lb.listenTCP(); err != nil {
+			return err
+		}
+	}
 
-	// for {
-	// 	clientConn, err := lb.listener.Accept()
-	// 	if err != nil {
-	// 		log.Printf("Error accepting connection: %v", err)
-	// 		continue
-	// 	}
+	go lb.runHealthChecks()
 
-	// 	go lb.handleConnection(clientConn)
-	// }
+	go lb.syncBackends()
 
-	// go lb.runHealthChecks()
+	if lb.Configuration.Metrics.Enabled {
+		go lb.serveMetrics()
+	}
 
 	return nil
 }
@@ -70,5 +345,8 @@ func (lb *LoadBalancer) Stop() {
 	if lb.StopChan != nil {
 		close(lb.StopChan)
 	}
+	if lb.tcpListener != nil {
+		lb.tcpListener.Close()
+	}
 	log.Println("Load Balancer stopped")
 }
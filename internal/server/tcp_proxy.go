@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shubhamojha1/heimdall/internal/config"
+)
+
+// listenTCP opens the layer 4 listener and starts accepting connections.
+func (lb *LoadBalancer) listenTCP() error {
+	addr := net.JoinHostPort(lb.Configuration.Listen.Address, fmt.Sprint(lb.Configuration.Listen.Port))
+
+	listenConfig := net.ListenConfig{KeepAlive: lb.keepAlivePeriod()}
+	listener, err := listenConfig.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	lb.mu.Lock()
+	lb.tcpListener = listener
+	if max := lb.Configuration.L4().TCP.MaxConnections; max > 0 {
+		lb.tcpSlots = make(chan struct{}, max)
+	}
+	lb.mu.Unlock()
+
+	log.Printf("Starting TCP Load Balancer on %s", addr)
+	go lb.acceptConnections(listener)
+	return nil
+}
+
+func (lb *LoadBalancer) acceptConnections(listener net.Listener) {
+	for {
+		clientConn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Error accepting connection: %v", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		if lb.tcpSlots == nil {
+			go lb.handleConnection(clientConn)
+			continue
+		}
+
+		select {
+		case lb.tcpSlots <- struct{}{}:
+			go func() {
+				defer func() { <-lb.tcpSlots }()
+				lb.handleConnection(clientConn)
+			}()
+		default:
+			log.Printf("[LB Server] Max connections reached, rejecting %s", clientConn.RemoteAddr())
+			clientConn.Close()
+		}
+	}
+}
+
+// keepAlivePeriod maps the L4 keepalive settings onto the net package's
+// convention: negative disables keepalives, zero uses the default period.
+func (lb *LoadBalancer) keepAlivePeriod() time.Duration {
+	tcp := lb.Configuration.L4().TCP
+	if !tcp.KeepAlive {
+		return -1
+	}
+	return tcp.KeepAliveTime
+}
+
+// backendAddress returns the host:port to dial for a backend, whose URL may or
+// may not carry a scheme.
+func backendAddress(backend *config.Backend) (string, error) {
+	if !strings.Contains(backend.URL, "://") {
+		return backend.URL, nil
+	}
+
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid backend url %q: %v", backend.URL, err)
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+
+	switch u.Scheme {
+	case "https":
+		return net.JoinHostPort(u.Hostname(), "443"), nil
+	case "http":
+		return net.JoinHostPort(u.Hostname(), "80"), nil
+	}
+	return "", fmt.Errorf("backend url %q has no port", backend.URL)
+}
+
+// closeWriter is implemented by connections that support half-close, such as
+// *net.TCPConn.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// firstByteConn calls onFirstByte the first time data is read from the
+// connection. It is only used when a balancer wants latency samples, since
+// wrapping the connection rules out the kernel's zero-copy splice.
+type firstByteConn struct {
+	net.Conn
+	once        sync.Once
+	onFirstByte func()
+}
+
+func (c *firstByteConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.once.Do(c.onFirstByte)
+	}
+	return n, err
+}
+
+func (c *firstByteConn) CloseWrite() error {
+	if cw, ok := c.Conn.(closeWriter); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+// splice copies bytes in both directions until both sides are done. When one
+// side finishes sending, the write half of the other connection is closed so
+// the peer sees EOF while the opposite direction keeps flowing.
+func splice(client, backend net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		if _, err := io.Copy(dst, src); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("[LB Server] Copy %s -> %s: %v", src.RemoteAddr(), dst.RemoteAddr(), err)
+		}
+		if cw, ok := dst.(closeWriter); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+
+	go pipe(backend, client)
+	go pipe(client, backend)
+	wg.Wait()
+}
@@ -0,0 +1,46 @@
+package server
+
+import "time"
+
+// the sliding window is kept as this many buckets
+const windowBuckets = 10
+
+// slidingWindow counts requests and failures over the last interval, in
+// buckets of a tenth of it.
+type slidingWindow struct {
+	buckets [windowBuckets]windowBucket
+}
+
+type windowBucket struct {
+	start              time.Time
+	requests, failures int
+}
+
+// add records one request at now.
+func (w *slidingWindow) add(interval time.Duration, failed bool, now time.Time) {
+	width := interval / windowBuckets
+	start := now.Truncate(width)
+	bucket := &w.buckets[(start.UnixNano()/int64(width))%windowBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = windowBucket{start: start}
+	}
+	bucket.requests++
+	if failed {
+		bucket.failures++
+	}
+}
+
+// counts returns the requests and failures recorded within interval of now.
+func (w *slidingWindow) counts(interval time.Duration, now time.Time) (requests, failures int) {
+	for _, bucket := range w.buckets {
+		if now.Sub(bucket.start) < interval {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+func (w *slidingWindow) reset() {
+	*w = slidingWindow{}
+}